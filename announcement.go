@@ -0,0 +1,11 @@
+package blog
+
+import "time"
+
+// AnnouncementService records the time up to which the posts published on schedule have been announced,
+// so that a post whose publish date passes while the blog is reloaded or stopped is still announced once
+type AnnouncementService interface {
+	// LastAnnounced returns the time up to which the posts have been announced, it is zero if none has been recorded
+	LastAnnounced() (time.Time, error)
+	SetLastAnnounced(t time.Time) error
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/url"
@@ -19,6 +20,8 @@ import (
 	"github.com/quantonganh/blog/kafka"
 	"github.com/quantonganh/blog/markdown"
 	"github.com/quantonganh/blog/rabbitmq"
+	"github.com/quantonganh/blog/smtp"
+	"github.com/quantonganh/blog/source"
 	"github.com/quantonganh/blog/sqlite"
 )
 
@@ -33,6 +36,11 @@ func main() {
 
 	viper.SetDefault("http.addr", ":8009")
 	viper.SetDefault("posts.dir", "posts")
+	viper.SetDefault("markdown.renderer", markdown.Blackfriday)
+	viper.SetDefault("markdown.highlight.lineNumbers", true)
+	viper.SetDefault("newsletter.store", blog.NewsletterStoreHTTP)
+	viper.SetDefault("newsletter.tokenExpiry", 48*time.Hour)
+	viper.SetDefault("newsletter.frequency", 7)
 
 	var config *blog.Config
 	if err := viper.Unmarshal(&config); err != nil {
@@ -46,7 +54,17 @@ func main() {
 	}
 	defer sentry.Flush(2 * time.Second)
 
-	posts, err := markdown.GetAllPosts(config.Posts.Dir)
+	converter, err := markdown.NewConverter(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	contentSource, err := source.New(config, embeddedPosts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	posts, err := markdown.GetAllPosts(contentSource, converter)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -55,9 +73,21 @@ func main() {
 		Timestamp().
 		Logger()
 
-	a, err := newApp(logger, config, posts)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := export(logger, config, contentSource, posts, os.Args[2:]); err != nil {
+			logger.Fatal().Err(err).Msg("error exporting static site")
+		}
+		return
+	}
+
+	a, err := newApp(logger, config, contentSource, posts)
 	if err != nil {
-		logger.Error().Err(err).Msg("error creating new app")
+		logger.Fatal().Err(err).Msg("error creating new app")
+	}
+
+	watch := len(os.Args) > 1 && os.Args[1] == "watch"
+	if watch {
+		a.httpServer.EnableLiveReload()
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -74,6 +104,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if watch {
+		go func() {
+			if err := a.httpServer.Watch(ctx, config.Posts.Dir); err != nil {
+				logger.Error().Err(err).Msg("error watching posts directory")
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
 	if err := a.Close(); err != nil {
@@ -82,21 +120,84 @@ func main() {
 	}
 }
 
+// export renders the whole blog into a directory which can be served by any static file host
+func export(logger zerolog.Logger, config *blog.Config, contentSource blog.ContentSource, posts []*blog.Post, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "public", "directory to write the rendered site to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	httpServer, err := http.NewServer(logger, config, posts)
+	if err != nil {
+		return err
+	}
+	httpServer.ContentSource = contentSource
+	defer func() {
+		_ = httpServer.SearchService.CloseIndex()
+	}()
+
+	baseURL, err := url.Parse(config.Site.BaseURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse URL %s", config.Site.BaseURL)
+	}
+	httpServer.Domain = baseURL.Hostname()
+
+	if err := httpServer.Export(*output); err != nil {
+		return err
+	}
+
+	logger.Info().Str("output", *output).Int("posts", len(posts)).Msg("static site exported")
+	return nil
+}
+
 type app struct {
-	db         *sqlite.DB
-	config     *blog.Config
-	httpServer *http.Server
+	db          *sqlite.DB
+	config      *blog.Config
+	httpServer  *http.Server
+	smtpService *smtp.Service
 }
 
-func newApp(logger zerolog.Logger, config *blog.Config, posts []*blog.Post) (*app, error) {
+func newApp(logger zerolog.Logger, config *blog.Config, contentSource blog.ContentSource, posts []*blog.Post) (*app, error) {
 	httpServer, err := http.NewServer(logger, config, posts)
 	if err != nil {
 		logger.Error().Err(err).Msg("error creating new HTTP server")
 		return nil, err
 	}
+	httpServer.ContentSource = contentSource
 
 	db := sqlite.NewDB("db/stats.db")
 
+	a := &app{
+		db:         db,
+		config:     config,
+		httpServer: httpServer,
+	}
+
+	if config.SMTP.Host != "" {
+		subscriberService := sqlite.NewSubscriberService(db)
+		a.smtpService = smtp.NewSMTPService(logger, config, sqlite.NewOutboxService(db), subscriberService, httpServer)
+		httpServer.SMTPService = a.smtpService
+		httpServer.SubscriberService = subscriberService
+		httpServer.DigestService = sqlite.NewDigestService(db)
+	}
+
+	newsletterService, err := newNewsletterService(config, db, a.smtpService)
+	if err != nil {
+		return nil, err
+	}
+	if newsletterService != nil {
+		httpServer.NewsletterService = newsletterService
+	}
+
+	// the subscription forms are not verified without the HMAC secret, which is only acceptable locally
+	if httpServer.NewsletterService != nil && config.Newsletter.HMAC.Secret == "" {
+		if config.Env != "local" {
+			return nil, errors.New("newsletter.hmac.secret is required to sign the subscription forms")
+		}
+		logger.Warn().Msg("newsletter.hmac.secret is not set, the subscription forms are not verified")
+	}
+
 	if config.Env != "local" {
 		queueService, err := rabbitmq.NewQueueService(config.AMQP.URL)
 		if err != nil {
@@ -112,13 +213,28 @@ func newApp(logger zerolog.Logger, config *blog.Config, posts []*blog.Post) (*ap
 
 		statService := sqlite.NewStatService(logger, db)
 		httpServer.StatService = statService
+
+		httpServer.DeliveryService = sqlite.NewDeliveryService(db)
+		httpServer.RedirectService = sqlite.NewRedirectService(db)
+		httpServer.AnnouncementService = sqlite.NewAnnouncementService(db)
 	}
 
-	return &app{
-		db:         db,
-		config:     config,
-		httpServer: httpServer,
-	}, nil
+	return a, nil
+}
+
+// newNewsletterService returns the newsletter service of the configured store, nil for the newsletter service at BaseURL
+func newNewsletterService(config *blog.Config, db *sqlite.DB, smtpService *smtp.Service) (blog.NewsletterService, error) {
+	switch config.Newsletter.Store {
+	case "", blog.NewsletterStoreHTTP:
+		return nil, nil
+	case blog.NewsletterStoreSQLite:
+		if smtpService == nil {
+			return nil, errors.New("the sqlite newsletter store requires smtp.host")
+		}
+		return sqlite.NewNewsletterService(db, smtpService, config.Newsletter.TokenExpiry), nil
+	default:
+		return nil, errors.Errorf("unknown newsletter store: %s", config.Newsletter.Store)
+	}
 }
 
 func (a *app) Run(ctx context.Context, logger zerolog.Logger) error {
@@ -137,6 +253,32 @@ func (a *app) Run(ctx context.Context, logger zerolog.Logger) error {
 		return err
 	}
 
+	if a.smtpService != nil {
+		a.smtpService.Start()
+	}
+
+	if a.httpServer.DigestService != nil && a.config.Newsletter.Cron.Spec != "" {
+		go func() {
+			if err := a.httpServer.SendDigests(ctx, a.config.Newsletter.Cron.Spec); err != nil {
+				logger.Error().Err(err).Msg("failed to send newsletter digests")
+			}
+		}()
+	}
+
+	go func() {
+		if err := a.httpServer.PublishScheduledPosts(ctx, time.Minute); err != nil {
+			logger.Error().Err(err).Msg("failed to publish scheduled posts")
+		}
+	}()
+
+	if a.httpServer.DeliveryService != nil {
+		go func() {
+			if err := a.httpServer.ProcessDeliveries(ctx, time.Minute); err != nil {
+				logger.Error().Err(err).Msg("failed to process webhook deliveries")
+			}
+		}()
+	}
+
 	if a.config.Env != "local" {
 		go func() {
 			if err := a.httpServer.ProcessActivityStream(ctx, a.config.IP2Location.Token); err != nil {
@@ -150,6 +292,12 @@ func (a *app) Run(ctx context.Context, logger zerolog.Logger) error {
 }
 
 func (a *app) Close() error {
+	if a.smtpService != nil {
+		if err := a.smtpService.Stop(); err != nil {
+			return err
+		}
+	}
+
 	if a.httpServer != nil {
 		if a.httpServer.SearchService != nil {
 			if err := a.httpServer.SearchService.CloseIndex(); err != nil {
@@ -0,0 +1,8 @@
+//go:build !embed
+
+package main
+
+import "io/fs"
+
+// embeddedPosts is nil unless the binary is built with the embed tag
+var embeddedPosts fs.FS
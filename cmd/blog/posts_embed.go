@@ -0,0 +1,23 @@
+//go:build embed
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// postsFS is the posts directory copied next to this file before building with the embed tag
+//
+//go:embed all:posts
+var postsFS embed.FS
+
+var embeddedPosts fs.FS
+
+func init() {
+	var err error
+	embeddedPosts, err = fs.Sub(postsFS, "posts")
+	if err != nil {
+		panic(err)
+	}
+}
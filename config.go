@@ -2,6 +2,18 @@ package blog
 
 import "time"
 
+// DefaultBranch is the branch whose pushes are processed and which the content is fetched from if none is set
+const DefaultBranch = "main"
+
+// Default limits of the subscription form, applied to the fields which are not set
+const (
+	DefaultSubscriptionIPLimit    = 10
+	DefaultSubscriptionEmailLimit = 3
+	DefaultSubscriptionWindow     = time.Hour
+	DefaultMinFormAge             = 2 * time.Second
+	DefaultMaxFormAge             = 24 * time.Hour
+)
+
 // Config represents the main config
 type Config struct {
 	Env  string
@@ -20,10 +32,33 @@ type Config struct {
 
 	Posts struct {
 		Dir string
+		// Source is the type of the content source: dir (default), embed, archive or git
+		Source  string
+		Archive string
+		Git     struct {
+			Repository string
+			Ref        string
+		}
+	}
+
+	Markdown struct {
+		Renderer  string
+		Highlight struct {
+			Style       string
+			LineNumbers bool
+			TabWidth    int
+		}
 	}
 
 	Webhook struct {
+		// Secret is the secret of the GitHub webhooks if GitHub.Secret is not set
 		Secret string
+		// Branch is the branch the content is fetched from, whose pushes are processed if the provider does not set one, main by default
+		Branch  string
+		GitHub  WebhookProvider
+		Gitea   WebhookProvider
+		GitLab  WebhookProvider
+		Generic WebhookProvider
 	}
 
 	DB struct {
@@ -39,16 +74,23 @@ type Config struct {
 		Port     int
 		Username string
 		Password string
+		// TLS is how the connection is secured: starttls, tls (implicit TLS, usually on port 465) or none,
+		// STARTTLS is used if the server supports it by default
+		TLS string
 	}
 
 	Newsletter struct {
 		Limiter struct {
 			Interval time.Duration
 		}
-		BaseURL   string
-		From      string
-		Frequency int
-		Cron      struct {
+		// Store is where the subscribers are stored: http (the newsletter service at BaseURL, default) or sqlite
+		Store string
+		// TokenExpiry is how long a confirmation link of the sqlite store is valid
+		TokenExpiry time.Duration
+		BaseURL     string
+		From        string
+		Frequency   int
+		Cron        struct {
 			Spec string
 		}
 		Product struct {
@@ -57,6 +99,7 @@ type Config struct {
 		HMAC struct {
 			Secret string
 		}
+		Subscription Subscription
 	}
 
 	Sentry struct {
@@ -76,6 +119,66 @@ type Config struct {
 	}
 }
 
+// WebhookProvider represents the config of the webhooks of a git hosting service, it is enabled if the secret is set
+type WebhookProvider struct {
+	Secret string
+	Branch string
+	// Header is the header of the HMAC signature of the generic webhooks, X-Signature by default
+	Header string
+}
+
+// Subscription represents the limits of the subscription form
+type Subscription struct {
+	// IPLimit and EmailLimit are the numbers of subscription attempts allowed per Window from an IP address
+	// and for an email, there is no limit if they are negative
+	IPLimit    int
+	EmailLimit int
+	Window     time.Duration
+	// MinFormAge and MaxFormAge bound the time between rendering the subscription form and submitting it,
+	// the form is signed with the HMAC secret and accepted only once. A negative MaxFormAge never expires the form.
+	MinFormAge time.Duration
+	MaxFormAge time.Duration
+	// TrustedProxies are the IP addresses or the CIDR ranges of the reverse proxies whose X-Forwarded-For
+	// and X-Real-Ip headers are trusted to find the IP address of a client, the headers are ignored by default
+	TrustedProxies []string
+}
+
+// Branch returns the branch the content is fetched from and whose pushes are processed if the provider does not set one
+func (c *Config) Branch() string {
+	if c.Webhook.Branch != "" {
+		return c.Webhook.Branch
+	}
+	return DefaultBranch
+}
+
+// PushBranch returns the branch whose pushes are processed by the webhook provider
+func (c *Config) PushBranch(provider WebhookProvider) string {
+	if provider.Branch != "" {
+		return provider.Branch
+	}
+	return c.Branch()
+}
+
+// WithDefaults returns the limits with the defaults applied to the fields which are not set
+func (s Subscription) WithDefaults() Subscription {
+	if s.IPLimit == 0 {
+		s.IPLimit = DefaultSubscriptionIPLimit
+	}
+	if s.EmailLimit == 0 {
+		s.EmailLimit = DefaultSubscriptionEmailLimit
+	}
+	if s.Window == 0 {
+		s.Window = DefaultSubscriptionWindow
+	}
+	if s.MinFormAge == 0 {
+		s.MinFormAge = DefaultMinFormAge
+	}
+	if s.MaxFormAge == 0 {
+		s.MaxFormAge = DefaultMaxFormAge
+	}
+	return s
+}
+
 // Item represents a navbar item
 type Item struct {
 	Text string
@@ -0,0 +1,53 @@
+package blog
+
+import (
+	"errors"
+	"time"
+)
+
+// Statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+	// DeliveryDuplicate is the status of a delivery of a commit which has already been delivered
+	DeliveryDuplicate = "duplicate"
+)
+
+// ErrDeliveryExists is returned when a delivery with the same ID has already been recorded
+var ErrDeliveryExists = errors.New("delivery already exists")
+
+// Delivery represents a webhook delivery and the result of its processing
+type Delivery struct {
+	ID       string
+	Provider string
+	Branch   string
+	// Before and After are the commit range of the push
+	Before string
+	After  string
+	// Push is the normalized push in JSON, so it can be processed again after a restart
+	Push []byte
+	// Reloaded reports whether the content has been reloaded with the push,
+	// Announce are then the URIs of the added posts which are still to be announced
+	Reloaded      bool
+	Announce      []string
+	Status        string
+	Attempts      int
+	Error         string
+	Duration      time.Duration
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+}
+
+// DeliveryService records the webhook deliveries
+type DeliveryService interface {
+	// CreateDelivery records a new delivery, it returns ErrDeliveryExists if the ID has already been recorded
+	CreateDelivery(d *Delivery) error
+	UpdateDelivery(d *Delivery) error
+	// IsCommitDelivered reports whether a pending or succeeded delivery has the commit as the head of its push
+	IsCommitDelivered(commit string) (bool, error)
+	// DueDeliveries returns the pending deliveries whose next attempt is due, oldest first
+	DueDeliveries(now time.Time) ([]*Delivery, error)
+	// RecentDeliveries returns the latest deliveries, newest first
+	RecentDeliveries(limit int) ([]*Delivery, error)
+}
@@ -0,0 +1,35 @@
+package blog
+
+import "time"
+
+// Statuses of a newsletter digest
+const (
+	DigestPending = "pending"
+	DigestSent    = "sent"
+)
+
+// Digest represents a newsletter listing the posts published in the window (From, To],
+// the window of a digest starts where the window of the previous one ends
+type Digest struct {
+	ID         int64
+	From       time.Time
+	To         time.Time
+	Status     string
+	Posts      int
+	Recipients int
+	CreatedAt  time.Time
+	SentAt     *time.Time
+}
+
+// DigestService records the newsletter digests and their recipients,
+// so that a digest interrupted by a restart is resumed without sending it twice to a subscriber
+type DigestService interface {
+	// LastDigest returns the latest digest, nil if no digest has been created yet
+	LastDigest() (*Digest, error)
+	// CreateDigest records a new pending digest and sets its ID
+	CreateDigest(d *Digest) error
+	// CompleteDigest marks the digest as sent and counts its recipients
+	CompleteDigest(d *Digest) error
+	IsDigestSentTo(id int64, email string) (bool, error)
+	AddDigestRecipient(id int64, email string, sentAt time.Time) error
+}
@@ -0,0 +1,36 @@
+package blog
+
+import "time"
+
+// Statuses of an outbound email
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// Email represents an email waiting in the outbound queue
+type Email struct {
+	ID      int64
+	To      string
+	Subject string
+	// Message is the whole message with its headers, as it is sent to the SMTP server
+	Message []byte
+	// Key identifies the email so that it is queued only once, it is optional
+	Key           string
+	Status        string
+	Attempts      int
+	Error         string
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+	SentAt        *time.Time
+}
+
+// OutboxService stores the outbound emails so that they are sent even after a restart
+type OutboxService interface {
+	// EnqueueEmail stores a new email and sets its ID, an email whose key has already been queued is ignored
+	EnqueueEmail(e *Email) error
+	UpdateEmail(e *Email) error
+	// DueEmails returns the pending emails whose next attempt is due, oldest first
+	DueEmails(now time.Time, limit int) ([]*Email, error)
+}
@@ -0,0 +1,128 @@
+package blog
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const (
+	// RSSContentNamespace is the namespace of the content:encoded element
+	RSSContentNamespace = "http://purl.org/rss/1.0/modules/content/"
+	// RSSDublinCoreNamespace is the namespace of the dc:creator element
+	RSSDublinCoreNamespace = "http://purl.org/dc/elements/1.1/"
+	// AtomNamespace is the namespace of an Atom feed
+	AtomNamespace = "http://www.w3.org/2005/Atom"
+	// JSONFeedVersion is the version of the JSON Feed specification
+	JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+)
+
+// RSS represents an RSS 2.0 feed
+type RSS struct {
+	XMLName          xml.Name   `xml:"rss"`
+	Version          string     `xml:"version,attr"`
+	ContentNamespace string     `xml:"xmlns:content,attr"`
+	DCNamespace      string     `xml:"xmlns:dc,attr"`
+	Channel          RSSChannel `xml:"channel"`
+}
+
+// RSSChannel represents the channel of an RSS feed
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []RSSItem `xml:"item"`
+}
+
+// RSSItem represents an item of an RSS feed
+type RSSItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	GUID        string     `xml:"guid"`
+	Description string     `xml:"description"`
+	Content     RSSContent `xml:"content:encoded"`
+	Creator     string     `xml:"dc:creator,omitempty"`
+	Categories  []string   `xml:"category"`
+	PubDate     string     `xml:"pubDate"`
+}
+
+// RSSContent represents the full content of an RSS item
+type RSSContent struct {
+	Content string `xml:",cdata"`
+}
+
+// AtomFeed represents an Atom 1.0 feed
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  *AtomAuthor `xml:"author,omitempty"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// AtomAuthor represents the author of an Atom feed
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// AtomLink represents a link of an Atom feed or entry
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// AtomEntry represents an entry of an Atom feed
+type AtomEntry struct {
+	Lang       string         `xml:"xml:lang,attr,omitempty"`
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []AtomLink     `xml:"link"`
+	Categories []AtomCategory `xml:"category"`
+	Summary    *AtomText      `xml:"summary,omitempty"`
+	Content    *AtomText      `xml:"content,omitempty"`
+}
+
+// AtomCategory represents a category of an Atom entry
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// AtomText represents a text construct of an Atom entry
+type AtomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// JSONFeed represents a JSON Feed 1.1
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+// JSONFeedAuthor represents the author of a JSON Feed
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// JSONFeedItem represents an item of a JSON Feed
+type JSONFeedItem struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title"`
+	ContentHTML   string     `json:"content_html"`
+	Summary       string     `json:"summary,omitempty"`
+	DatePublished *time.Time `json:"date_published,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Language      string     `json:"language,omitempty"`
+}
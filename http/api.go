@@ -0,0 +1,296 @@
+package http
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	apiPrefix          = "/api/v1"
+	defaultSuggestSize = 5
+	maxAPISize         = 100
+)
+
+type searchResponse struct {
+	Query  string                         `json:"query"`
+	Total  int                            `json:"total"`
+	Page   int                            `json:"page"`
+	Size   int                            `json:"size"`
+	Hits   []*searchHit                   `json:"hits"`
+	Facets map[string][]*blog.SearchFacet `json:"facets"`
+}
+
+type searchHit struct {
+	Title    string          `json:"title"`
+	URI      string          `json:"uri"`
+	Section  string          `json:"section,omitempty"`
+	Date     time.Time       `json:"date"`
+	Snippets []template.HTML `json:"snippets"`
+	Score    float64         `json:"score"`
+}
+
+type suggestResponse struct {
+	Titles []*titleSuggestion `json:"titles"`
+	Tags   []string           `json:"tags"`
+}
+
+type titleSuggestion struct {
+	Title string `json:"title"`
+	URI   string `json:"uri"`
+}
+
+// apiRoute describes a read-only JSON endpoint, it is used both to register the handler and to generate the OpenAPI document
+type apiRoute struct {
+	path       string
+	summary    string
+	parameters []*apiParameter
+	// response is a value of the response type, its schema is generated from the JSON field tags
+	response interface{}
+	handler  appHandler
+}
+
+// apiParameter describes a path or query parameter
+type apiParameter struct {
+	name        string
+	in          string
+	description string
+	typ         string
+	required    bool
+}
+
+func queryParameter(name, typ, description string) *apiParameter {
+	return &apiParameter{
+		name:        name,
+		in:          "query",
+		description: description,
+		typ:         typ,
+	}
+}
+
+func pathParameter(name, description string) *apiParameter {
+	return &apiParameter{
+		name:        name,
+		in:          "path",
+		description: description,
+		typ:         "string",
+		required:    true,
+	}
+}
+
+var (
+	pageParameter = queryParameter("page", "integer", "Page number, starting from 1")
+	sizeParameter = queryParameter("size", "integer", "Number of items per page, up to 100")
+)
+
+// apiRoutes returns the endpoints of the JSON API
+func (s *Server) apiRoutes() []*apiRoute {
+	return []*apiRoute{
+		{
+			path:    "/posts",
+			summary: "List the published posts, newest first",
+			parameters: []*apiParameter{
+				queryParameter("tag", "string", "Only the posts having this tag"),
+				queryParameter("category", "string", "Only the posts in this category"),
+				queryParameter("lang", "string", "Only the posts written in this language: en or vi"),
+				queryParameter("from", "string", "Only the posts published on or after this date (YYYY-MM-DD)"),
+				queryParameter("to", "string", "Only the posts published on or before this date (YYYY-MM-DD)"),
+				queryParameter("fields", "string", "Comma separated list of the fields to return"),
+				pageParameter,
+				sizeParameter,
+			},
+			response: &postsResponse{Items: []*postResource{}},
+			handler:  s.apiPostsHandler,
+		},
+		{
+			path:    "/posts/{path:.+}",
+			summary: "Get a post by its URI with the related, translated, previous and next posts",
+			parameters: []*apiParameter{
+				pathParameter("path", "URI of the post without the leading slash, e.g. 2019/09/19/test.md"),
+				queryParameter("fields", "string", "Comma separated list of the fields to return"),
+			},
+			response: &postDetail{postResource: &postResource{}},
+			handler:  s.apiPostHandler,
+		},
+		{
+			path:     "/tags",
+			summary:  "List the tags with their number of posts",
+			response: []*termCount{},
+			handler:  s.apiTagsHandler,
+		},
+		{
+			path:     "/categories",
+			summary:  "List the categories with their number of posts",
+			response: []*termCount{},
+			handler:  s.apiCategoriesHandler,
+		},
+		{
+			path:     "/archives",
+			summary:  "List the number of posts by year and month",
+			response: []*yearArchive{},
+			handler:  s.apiArchivesHandler,
+		},
+		{
+			path:    "/search",
+			summary: "Search the posts with the query string syntax",
+			parameters: []*apiParameter{
+				queryParameter("q", "string", "Query string: phrases, field:term, fuzzy term~1"),
+				queryParameter("sort", "string", "relevance (default) or date"),
+				pageParameter,
+				sizeParameter,
+			},
+			response: &searchResponse{},
+			handler:  s.apiSearchHandler,
+		},
+		{
+			path:    "/suggest",
+			summary: "Complete a prefix with the titles and the tags",
+			parameters: []*apiParameter{
+				queryParameter("q", "string", "Prefix to complete"),
+				sizeParameter,
+			},
+			response: &suggestResponse{},
+			handler:  s.apiSuggestHandler,
+		},
+	}
+}
+
+// newAPIRoute registers a JSON endpoint under the versioned API prefix
+func (s *Server) newAPIRoute(route *apiRoute) *mux.Route {
+	return s.router.HandleFunc(apiPrefix+route.path, s.apiError(route.handler)).Methods(http.MethodGet)
+}
+
+// apiError writes the error as a JSON message
+func (s *Server) apiError(fn appHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+
+		log := zerolog.Ctx(r.Context())
+		log.UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Str(zerolog.ErrorFieldName, err.Error())
+		})
+
+		clientError, ok := err.(ClientError)
+		if !ok {
+			sentry.CaptureException(err)
+			_ = writeJSON(w, http.StatusInternalServerError, &Error{Message: errOops})
+			return
+		}
+
+		status, _ := clientError.Headers()
+		_ = writeJSON(w, status, &Error{Message: clientError.Body()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// getSize returns the size query parameter, bounded by maxAPISize
+func getSize(r *http.Request, defaultSize int) (int, error) {
+	value := r.FormValue("size")
+	if value == "" {
+		return defaultSize, nil
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 || size > maxAPISize {
+		return 0, NewError(err, http.StatusBadRequest, "size must be between 1 and "+strconv.Itoa(maxAPISize))
+	}
+
+	return size, nil
+}
+
+func (s *Server) apiSearchHandler(w http.ResponseWriter, r *http.Request) error {
+	defaultSize, err := getPostsPerPage()
+	if err != nil {
+		return err
+	}
+	size, err := getSize(r, defaultSize)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	searchRequest := &blog.SearchRequest{
+		Query: r.FormValue("q"),
+		Sort:  r.FormValue("sort"),
+		Page:  page,
+		Size:  size,
+	}
+	result, err := s.SearchService.Search(searchRequest)
+	if err != nil {
+		if errors.Cause(err) == blog.ErrInvalidQuery {
+			return NewError(err, http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+
+	resolveHits(s.content().PostService, result)
+
+	resp := &searchResponse{
+		Query:  searchRequest.Query,
+		Total:  result.Total,
+		Page:   page,
+		Size:   size,
+		Hits:   make([]*searchHit, 0, len(result.Hits)),
+		Facets: result.Facets,
+	}
+	for _, hit := range result.Hits {
+		resp.Hits = append(resp.Hits, &searchHit{
+			Title:    hit.Post.Title,
+			URI:      hit.Post.URI,
+			Section:  hit.Section,
+			Date:     hit.Post.Date.Time,
+			Snippets: hit.Fragments,
+			Score:    hit.Score,
+		})
+	}
+
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiSuggestHandler(w http.ResponseWriter, r *http.Request) error {
+	size, err := getSize(r, defaultSuggestSize)
+	if err != nil {
+		return err
+	}
+
+	suggestions, err := s.SearchService.Suggest(r.FormValue("q"), size)
+	if err != nil {
+		return err
+	}
+
+	resp := &suggestResponse{
+		Titles: make([]*titleSuggestion, 0, len(suggestions.URIs)),
+		Tags:   make([]string, 0, len(suggestions.Tags)),
+	}
+	for _, uri := range suggestions.URIs {
+		if post := s.content().PostService.GetPostByURI(uri); post != nil {
+			resp.Titles = append(resp.Titles, &titleSuggestion{
+				Title: post.Title,
+				URI:   post.URI,
+			})
+		}
+	}
+	resp.Tags = append(resp.Tags, suggestions.Tags...)
+
+	return writeJSON(w, http.StatusOK, resp)
+}
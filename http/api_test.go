@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+func TestAPISearchHandler(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/api/v1/search?q=test&size=5", nil)
+	require.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	var resp searchResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, 1, resp.Total)
+	assert.Equal(t, 5, resp.Size)
+	require.Equal(t, 1, len(resp.Hits))
+	assert.Equal(t, "Test", resp.Hits[0].Title)
+	assert.Equal(t, "/2019/09/19/test.md", resp.Hits[0].URI)
+	assert.Greater(t, resp.Hits[0].Score, 0.0)
+	assert.Equal(t, 1, resp.Facets["tags"][0].Count)
+}
+
+func TestAPISearchHandlerInvalidQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, target := range []string{"/api/v1/search?q=%22test", "/api/v1/search?q=test&size=1000"} {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		s.router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		var e Error
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&e))
+		assert.NotEmpty(t, e.Message)
+	}
+}
+
+func TestAPISuggestHandler(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/api/v1/suggest?q=Te", nil)
+	require.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp suggestResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, []*titleSuggestion{{Title: "Test", URI: "/2019/09/19/test.md"}}, resp.Titles)
+	assert.Equal(t, []string{"test"}, resp.Tags)
+}
+
+func TestResolveHits(t *testing.T) {
+	postService := markdown.NewPostService([]*blog.Post{parseTestPost(t, 1, "Resolved.")}, nil)
+	result := &blog.SearchResult{
+		Total: 12,
+		Hits: []*blog.SearchHit{
+			{URI: "/2021/01/01/day1.md"},
+			{URI: "/2021/01/02/day2.md"},
+		},
+	}
+
+	resolveHits(postService, result)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "Day1", result.Hits[0].Post.Title)
+	assert.Equal(t, 12, result.Total)
+}
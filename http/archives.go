@@ -5,5 +5,5 @@ import (
 )
 
 func (s *Server) archivesHandler(w http.ResponseWriter, r *http.Request) error {
-	return s.Renderer.RenderArchives(w)
+	return s.content().Renderer.RenderArchives(w)
 }
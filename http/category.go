@@ -4,14 +4,23 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/blog"
 )
 
 func (s *Server) categoryHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	lang, err := getLang(r)
+	if err != nil {
+		return err
+	}
+
 	category := mux.Vars(r)["categoryName"]
 
-	postsByCategory := s.PostService.GetPostsByCategory(category)
+	postsByCategory := content.PostService.GetPostsByCategory(category)
 
-	if err := s.Renderer.RenderPosts(w, r, postsByCategory); err != nil {
+	if err := content.Renderer.RenderPosts(w, r, blog.FilterByLang(postsByCategory, lang)); err != nil {
 		return err
 	}
 
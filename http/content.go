@@ -0,0 +1,323 @@
+package http
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/blog"
+)
+
+const dateLayout = "2006-01-02"
+
+type postsResponse struct {
+	Total int             `json:"total"`
+	Page  int             `json:"page"`
+	Size  int             `json:"size"`
+	Items []*postResource `json:"items"`
+}
+
+type postResource struct {
+	URI         string          `json:"uri"`
+	Title       string          `json:"title"`
+	Date        time.Time       `json:"date"`
+	Description string          `json:"description"`
+	Images      []string        `json:"images"`
+	Categories  []string        `json:"categories"`
+	Tags        []string        `json:"tags"`
+	Summary     template.HTML   `json:"summary"`
+	Content     template.HTML   `json:"content"`
+	TOC         []*blog.Heading `json:"toc"`
+	Lang        string          `json:"lang"`
+	WordCount   int             `json:"word_count"`
+	ReadingTime int             `json:"reading_time"`
+}
+
+type postDetail struct {
+	*postResource
+	Related      []*postLink `json:"related"`
+	Translations []*postLink `json:"translations"`
+	Previous     *postLink   `json:"previous"`
+	Next         *postLink   `json:"next"`
+}
+
+type postLink struct {
+	URI   string    `json:"uri"`
+	Title string    `json:"title"`
+	Date  time.Time `json:"date"`
+	Lang  string    `json:"lang"`
+}
+
+type termCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type yearArchive struct {
+	Year   string          `json:"year"`
+	Count  int             `json:"count"`
+	Months []*monthArchive `json:"months"`
+}
+
+type monthArchive struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+func newPostResource(p *blog.Post) *postResource {
+	return &postResource{
+		URI:         p.URI,
+		Title:       p.Title,
+		Date:        p.Date.Time,
+		Description: p.Description,
+		Images:      p.Images,
+		Categories:  p.Categories,
+		Tags:        p.Tags,
+		Summary:     p.Summary,
+		Content:     p.Content,
+		TOC:         p.TOC,
+		Lang:        p.Lang,
+		WordCount:   p.WordCount,
+		ReadingTime: p.ReadingTime,
+	}
+}
+
+func newPostLink(p *blog.Post) *postLink {
+	if p == nil {
+		return nil
+	}
+
+	return &postLink{
+		URI:   p.URI,
+		Title: p.Title,
+		Date:  p.Date.Time,
+		Lang:  p.Lang,
+	}
+}
+
+// getFields returns the fields to be selected, nil means all the fields
+func getFields(r *http.Request) []string {
+	value := r.FormValue("fields")
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
+// selectFields returns the JSON object of v with only the given fields
+func selectFields(v interface{}, fields []string) (interface{}, error) {
+	if fields == nil {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		value, ok := m[field]
+		if !ok {
+			return nil, NewError(nil, http.StatusBadRequest, "unknown field: "+field)
+		}
+		selected[field] = value
+	}
+
+	return selected, nil
+}
+
+// parseDate parses the date query parameter, the zero time is returned if it is empty
+func parseDate(r *http.Request, name string) (time.Time, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, NewError(err, http.StatusBadRequest, name+" must be a date in the YYYY-MM-DD format")
+	}
+
+	return date, nil
+}
+
+func (s *Server) apiPostsHandler(w http.ResponseWriter, r *http.Request) error {
+	defaultSize, err := getPostsPerPage()
+	if err != nil {
+		return err
+	}
+	size, err := getSize(r, defaultSize)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	from, err := parseDate(r, "from")
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(r, "to")
+	if err != nil {
+		return err
+	}
+	lang, err := getLang(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		tag      = r.FormValue("tag")
+		category = r.FormValue("category")
+		posts    []*blog.Post
+	)
+	for _, p := range blog.FilterByLang(s.content().PostService.GetAllPosts(), lang) {
+		if tag != "" && !blog.Contains(p.Tags, tag) {
+			continue
+		}
+		if category != "" && !blog.Contains(p.Categories, category) {
+			continue
+		}
+		if !from.IsZero() && p.Date.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !p.Date.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		posts = append(posts, p)
+	}
+
+	resp := &postsResponse{
+		Total: len(posts),
+		Page:  page,
+		Size:  size,
+		Items: make([]*postResource, 0, size),
+	}
+	for i := (page - 1) * size; i < len(posts) && i < page*size; i++ {
+		resp.Items = append(resp.Items, newPostResource(posts[i]))
+	}
+
+	fields := getFields(r)
+	if fields == nil {
+		return writeJSON(w, http.StatusOK, resp)
+	}
+
+	items := make([]interface{}, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		selected, err := selectFields(item, fields)
+		if err != nil {
+			return err
+		}
+		items = append(items, selected)
+	}
+
+	return writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": resp.Total,
+		"page":  resp.Page,
+		"size":  resp.Size,
+		"items": items,
+	})
+}
+
+func (s *Server) apiPostHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	uri := "/" + mux.Vars(r)["path"]
+	p := content.PostService.GetPostByURI(uri)
+	if p == nil {
+		return NewError(nil, http.StatusNotFound, "post not found")
+	}
+
+	detail := &postDetail{
+		postResource: newPostResource(p),
+		Related:      make([]*postLink, 0),
+		Translations: make([]*postLink, 0),
+	}
+	for _, related := range content.PostService.GetRelatedPosts(p) {
+		detail.Related = append(detail.Related, newPostLink(related))
+	}
+	for _, translation := range content.PostService.GetTranslations(p) {
+		detail.Translations = append(detail.Translations, newPostLink(translation))
+	}
+	previousPost, nextPost := content.PostService.GetPreviousAndNextPost(p)
+	detail.Previous = newPostLink(previousPost)
+	detail.Next = newPostLink(nextPost)
+
+	resp, err := selectFields(detail, getFields(r))
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiTagsHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	postsPerTag := content.PostService.GetPostsPerTag()
+	tags := make([]*termCount, 0, len(postsPerTag))
+	for _, tag := range content.PostService.GetAllTags() {
+		tags = append(tags, &termCount{
+			Name:  tag,
+			Count: postsPerTag[tag],
+		})
+	}
+
+	return writeJSON(w, http.StatusOK, tags)
+}
+
+func (s *Server) apiCategoriesHandler(w http.ResponseWriter, r *http.Request) error {
+	postsByCategory := s.content().PostService.GetAllCategories()
+	categories := make([]*termCount, 0, len(postsByCategory))
+	for category, posts := range postsByCategory {
+		categories = append(categories, &termCount{
+			Name:  category,
+			Count: len(posts),
+		})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+
+	return writeJSON(w, http.StatusOK, categories)
+}
+
+func (s *Server) apiArchivesHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	var (
+		monthsInYear = content.PostService.GetMonthsInYear()
+		postsByMonth = content.PostService.GetPostsByMonth()
+		archives     = make([]*yearArchive, 0)
+	)
+	for _, year := range content.PostService.GetYears() {
+		archive := &yearArchive{
+			Year:   year,
+			Months: make([]*monthArchive, 0, len(monthsInYear[year])),
+		}
+		for _, month := range monthsInYear[year] {
+			count := len(postsByMonth[year][month])
+			archive.Count += count
+			archive.Months = append(archive.Months, &monthArchive{
+				Month: month,
+				Count: count,
+			})
+		}
+		archives = append(archives, archive)
+	}
+
+	return writeJSON(w, http.StatusOK, archives)
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getJSON(t *testing.T, target string, v interface{}) int {
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, target, nil)
+	require.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(v))
+	return rr.Code
+}
+
+func TestAPIPostsHandler(t *testing.T) {
+	t.Parallel()
+
+	var resp postsResponse
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/posts?tag=test&from=2019-09-19&to=2019-09-19", &resp))
+	assert.Equal(t, 1, resp.Total)
+	require.Equal(t, 1, len(resp.Items))
+	assert.Equal(t, "/2019/09/19/test.md", resp.Items[0].URI)
+	assert.Equal(t, "<p>Test.</p>\n", string(resp.Items[0].Content))
+
+	resp = postsResponse{}
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/posts?category=unknown", &resp))
+	assert.Equal(t, 0, resp.Total)
+	assert.Empty(t, resp.Items)
+
+	resp = postsResponse{}
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/posts?to=2019-09-18", &resp))
+	assert.Equal(t, 0, resp.Total)
+
+	var selected struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/posts?fields=title,uri", &selected))
+	assert.Equal(t, []map[string]interface{}{{"title": "Test", "uri": "/2019/09/19/test.md"}}, selected.Items)
+
+	var e Error
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, "/api/v1/posts?fields=body", &e))
+	assert.Equal(t, "unknown field: body", e.Message)
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, "/api/v1/posts?from=yesterday", &e))
+}
+
+func TestAPIPostHandler(t *testing.T) {
+	t.Parallel()
+
+	var detail map[string]interface{}
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/posts/2019/09/19/test.md", &detail))
+	assert.Equal(t, "Test", detail["title"])
+	assert.Equal(t, []interface{}{}, detail["related"])
+	assert.Nil(t, detail["previous"])
+
+	var e Error
+	assert.Equal(t, http.StatusNotFound, getJSON(t, "/api/v1/posts/2019/09/19/unknown.md", &e))
+	assert.Equal(t, "post not found", e.Message)
+}
+
+func TestAPITaxonomyHandlers(t *testing.T) {
+	t.Parallel()
+
+	var tags []*termCount
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/tags", &tags))
+	assert.Equal(t, []*termCount{{Name: "test", Count: 1}}, tags)
+
+	var categories []*termCount
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/categories", &categories))
+	assert.Equal(t, []*termCount{{Name: "Du lịch", Count: 1}}, categories)
+
+	var archives []*yearArchive
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/archives", &archives))
+	assert.Equal(t, []*yearArchive{{Year: "2019", Count: 1, Months: []*monthArchive{{Month: "09", Count: 1}}}}, archives)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	require.Equal(t, http.StatusOK, getJSON(t, "/api/v1/openapi.json", &doc))
+	assert.Equal(t, openAPIVersion, doc.OpenAPI)
+	for _, route := range s.apiRoutes() {
+		assert.Contains(t, doc.Paths, muxVariable.ReplaceAllString(apiPrefix+route.path, "{$1}"))
+	}
+	assert.Contains(t, doc.Paths, "/api/v1/posts/{path}")
+
+	schema := schemaOf(reflect.TypeOf(&postDetail{}))
+	properties := schema["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "title")
+	assert.Contains(t, properties, "related")
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"}, properties["date"])
+}
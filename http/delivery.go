@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/ui/html"
+)
+
+const (
+	maxDeliveryAttempts = 5
+	// deliveryRetryDelay is the delay before the second attempt, it is doubled after each failed attempt
+	deliveryRetryDelay = 30 * time.Second
+	numberOfDeliveries = 50
+	shortCommitLength  = 7
+)
+
+// newDelivery returns a pending delivery of the push, its ID is the hash of the payload if the provider does not send one
+func newDelivery(provider webhookProvider, r *http.Request, body []byte, push *pushEvent) (*blog.Delivery, error) {
+	data, err := json.Marshal(push)
+	if err != nil {
+		return nil, err
+	}
+
+	id := provider.deliveryID(r)
+	if id == "" {
+		hash := sha256.Sum256(body)
+		id = hex.EncodeToString(hash[:])
+	}
+
+	now := time.Now()
+	return &blog.Delivery{
+		ID:            id,
+		Provider:      provider.name(),
+		Branch:        push.Branch,
+		Before:        push.Before,
+		After:         push.After,
+		Push:          data,
+		Status:        blog.DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// acceptDelivery records the delivery and wakes the worker up, a push of a commit which has already been delivered is
+// recorded as a duplicate and is not processed again
+func (s *Server) acceptDelivery(d *blog.Delivery) error {
+	if d.After != "" {
+		delivered, err := s.DeliveryService.IsCommitDelivered(d.After)
+		if err != nil {
+			return err
+		}
+		if delivered {
+			d.Status = blog.DeliveryDuplicate
+		}
+	}
+
+	if err := s.DeliveryService.CreateDelivery(d); err != nil {
+		return err
+	}
+
+	if d.Status == blog.DeliveryPending {
+		select {
+		case s.deliveryAccepted <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// ProcessDeliveries processes the pending webhook deliveries as soon as they are accepted,
+// and checks every interval for the deliveries to retry until ctx is done
+func (s *Server) ProcessDeliveries(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.processDueDeliveries(ctx, time.Now()); err != nil {
+			s.logger.Error().Err(err).Msg("failed to process webhook deliveries")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-s.deliveryAccepted:
+		}
+	}
+}
+
+func (s *Server) processDueDeliveries(ctx context.Context, now time.Time) error {
+	deliveries, err := s.DeliveryService.DueDeliveries(now)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if err := s.processDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processDelivery processes the push of the delivery, then records the result: a failed delivery is retried
+// with an exponential backoff until it reaches the maximum number of attempts
+func (s *Server) processDelivery(ctx context.Context, d *blog.Delivery) error {
+	start := time.Now()
+	var push pushEvent
+	err := json.Unmarshal(d.Push, &push)
+	// the content is reloaded once, a retry only announces the posts which are not announced yet
+	if err == nil && !d.Reloaded {
+		d.Announce, err = s.reloadPush(ctx, &push)
+		d.Reloaded = err == nil
+	}
+	if err == nil {
+		d.Announce, err = s.announcePosts(d.Announce)
+	}
+
+	d.Attempts++
+	d.Duration = time.Since(start)
+	switch {
+	case err == nil:
+		d.Status = blog.DeliverySucceeded
+		d.Error = ""
+	case d.Attempts >= maxDeliveryAttempts:
+		d.Status = blog.DeliveryFailed
+		d.Error = err.Error()
+	default:
+		d.Error = err.Error()
+		d.NextAttemptAt = start.Add(deliveryRetryDelay << (d.Attempts - 1))
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("delivery", d.ID).Int("attempts", d.Attempts).Msg("failed to process webhook delivery")
+	}
+
+	return s.DeliveryService.UpdateDelivery(d)
+}
+
+// deliveriesHandler lists the recent deliveries to the clients authenticated with the secret of a webhook provider
+// as the password of the basic authentication
+func (s *Server) deliveriesHandler(config *blog.Config) appHandler {
+	var secrets []string
+	for _, secret := range []string{config.Webhook.Secret, config.Webhook.GitHub.Secret, config.Webhook.Gitea.Secret, config.Webhook.GitLab.Secret, config.Webhook.Generic.Secret} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !isWebhookSecret(r, secrets) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="deliveries"`)
+			return NewError(nil, http.StatusUnauthorized, "Unauthorized")
+		}
+
+		deliveries, err := s.DeliveryService.RecentDeliveries(numberOfDeliveries)
+		if err != nil {
+			return err
+		}
+
+		tmpl := html.Parse(template.FuncMap{
+			"shortCommit": shortCommit,
+		}, "deliveries.html")
+		data := map[string]interface{}{
+			"deliveries": deliveries,
+		}
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// isWebhookSecret reports whether the password of the basic authentication of the request is one of the secrets
+func isWebhookSecret(r *http.Request, secrets []string) bool {
+	_, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	for _, secret := range secrets {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(secret)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > shortCommitLength {
+		return commit[:shortCommitLength]
+	}
+
+	return commit
+}
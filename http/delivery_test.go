@@ -0,0 +1,132 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/sqlite"
+)
+
+func TestDeliveries(t *testing.T) {
+	t.Parallel()
+
+	config := &blog.Config{}
+	config.Webhook.Secret = "secret"
+	ss := newWebhookServer(t, config)
+	db := sqlite.NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	ss.DeliveryService = sqlite.NewDeliveryService(db)
+	queue := &fakeQueueService{
+		messages: make(map[string][][]byte),
+	}
+	ss.QueueService = queue
+
+	deliver := func(id, after, file string) int {
+		payload := []byte(fmt.Sprintf(`{"ref": "refs/heads/main", "after": %q, "commits": [{"added": [%q]}]}`, after, file))
+		request, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+		require.NoError(t, err)
+		request.Header.Set("X-GitHub-Delivery", id)
+		request.Header.Set("X-Hub-Signature-256", "sha256="+sign(config.Webhook.Secret, payload))
+		rr := httptest.NewRecorder()
+		ss.router.ServeHTTP(rr, request)
+		return rr.Code
+	}
+	process := func(now time.Time) {
+		require.NoError(t, ss.processDueDeliveries(context.Background(), now))
+	}
+	statuses := func() map[string]*blog.Delivery {
+		deliveries, err := ss.DeliveryService.RecentDeliveries(numberOfDeliveries)
+		require.NoError(t, err)
+		m := make(map[string]*blog.Delivery)
+		for _, d := range deliveries {
+			m[d.ID] = d
+		}
+		return m
+	}
+
+	// the push is processed in the background
+	require.Equal(t, http.StatusAccepted, deliver("d1", "c1", "2021/01/01/hello.md"))
+	assert.Nil(t, ss.content().PostService.GetPostByURI("/2021/01/01/hello.md"))
+	process(time.Now())
+	assert.NotNil(t, ss.content().PostService.GetPostByURI("/2021/01/01/hello.md"))
+	assert.Len(t, queue.messages["added-posts"], 1)
+	assert.Equal(t, blog.DeliverySucceeded, statuses()["d1"].Status)
+
+	// a redelivery and another delivery of the same commit are not processed again
+	require.Equal(t, http.StatusOK, deliver("d1", "c1", "2021/01/01/hello.md"))
+	require.Equal(t, http.StatusAccepted, deliver("d2", "c1", "2021/01/01/hello.md"))
+	assert.Equal(t, blog.DeliveryDuplicate, statuses()["d2"].Status)
+
+	// a post which is already served is not announced again
+	require.Equal(t, http.StatusAccepted, deliver("d3", "c2", "2021/01/01/hello.md"))
+	process(time.Now())
+	assert.Equal(t, blog.DeliverySucceeded, statuses()["d3"].Status)
+	assert.Len(t, queue.messages["added-posts"], 1)
+
+	// a failed delivery is retried with a backoff until the maximum number of attempts
+	require.Equal(t, http.StatusAccepted, deliver("d4", "c3", "2021/01/01/missing.md"))
+	process(time.Now())
+	d := statuses()["d4"]
+	assert.Equal(t, blog.DeliveryPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Contains(t, d.Error, "missing.md")
+	assert.True(t, d.NextAttemptAt.After(time.Now()))
+	process(time.Now())
+	assert.Equal(t, 1, statuses()["d4"].Attempts)
+	for i := 1; i < maxDeliveryAttempts; i++ {
+		process(time.Now().Add(24 * time.Hour))
+	}
+	d = statuses()["d4"]
+	assert.Equal(t, blog.DeliveryFailed, d.Status)
+	assert.Equal(t, maxDeliveryAttempts, d.Attempts)
+
+	// the posts which are not announced are announced by the next attempt, after the content has been reloaded
+	queue.err = errors.New("queue is down")
+	require.Equal(t, http.StatusAccepted, deliver("d5", "c4", "2021/01/02/world.md"))
+	process(time.Now())
+	d = statuses()["d5"]
+	assert.Equal(t, blog.DeliveryPending, d.Status)
+	assert.True(t, d.Reloaded)
+	assert.Equal(t, []string{"/2021/01/02/world.md"}, d.Announce)
+	assert.NotNil(t, ss.content().PostService.GetPostByURI("/2021/01/02/world.md"))
+	queue.err = nil
+	process(time.Now().Add(24 * time.Hour))
+	d = statuses()["d5"]
+	assert.Equal(t, blog.DeliverySucceeded, d.Status)
+	assert.Empty(t, d.Announce)
+	assert.Len(t, queue.messages["added-posts"], 2)
+
+	// the deliveries are listed to the clients knowing the webhook secret, without the errors
+	list := func(password string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/webhook/deliveries", nil)
+		require.NoError(t, err)
+		if password != "" {
+			request.SetBasicAuth("admin", password)
+		}
+		ss.router.ServeHTTP(rr, request)
+		return rr
+	}
+	assert.Equal(t, http.StatusUnauthorized, list("").Code)
+	assert.Equal(t, http.StatusUnauthorized, list("wrong").Code)
+	rr := list(config.Webhook.Secret)
+	require.Equal(t, http.StatusOK, rr.Code)
+	for _, status := range []string{blog.DeliverySucceeded, blog.DeliveryDuplicate, blog.DeliveryFailed} {
+		assert.Contains(t, rr.Body.String(), status)
+	}
+	assert.NotContains(t, rr.Body.String(), "missing.md")
+}
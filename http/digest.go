@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/quantonganh/blog"
+)
+
+// SendDigests sends the digest of the new posts to the active subscribers at the times of the cron spec.
+// A digest interrupted by a restart is resumed at start, and a digest missed while the blog was stopped is sent at start.
+func (s *Server) SendDigests(ctx context.Context, spec string) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return errors.Wrapf(err, "invalid cron spec: %s", spec)
+	}
+
+	now := time.Now()
+	missed, err := s.isDigestMissed(schedule, now)
+	if err != nil {
+		return err
+	}
+	if missed {
+		if err := s.sendDigests(now); err != nil {
+			s.logger.Error().Err(err).Msg("failed to send newsletter digest")
+		}
+	}
+
+	for {
+		next := schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+			if err := s.sendDigests(next); err != nil {
+				s.logger.Error().Err(err).Msg("failed to send newsletter digest")
+			}
+		}
+	}
+}
+
+// isDigestMissed reports whether the last digest is pending or a scheduled digest should have been sent since then
+func (s *Server) isDigestMissed(schedule cron.Schedule, now time.Time) (bool, error) {
+	last, err := s.DigestService.LastDigest()
+	if err != nil {
+		return false, err
+	}
+	if last == nil {
+		return false, nil
+	}
+
+	return last.Status == blog.DigestPending || !schedule.Next(last.To).After(now), nil
+}
+
+// sendDigests sends the pending digest first, then the digest of the posts published
+// since the end of the last digest until at. The first digest covers the last Frequency days.
+func (s *Server) sendDigests(at time.Time) error {
+	for {
+		d, err := s.DigestService.LastDigest()
+		if err != nil {
+			return err
+		}
+
+		if d == nil || d.Status == blog.DigestSent {
+			from := at.AddDate(0, 0, -s.config.Newsletter.Frequency)
+			if d != nil {
+				from = d.To
+			}
+			if !at.After(from) {
+				return nil
+			}
+
+			d = &blog.Digest{
+				From:      from,
+				To:        at,
+				Status:    blog.DigestPending,
+				CreatedAt: time.Now(),
+			}
+			if err := s.DigestService.CreateDigest(d); err != nil {
+				return err
+			}
+		}
+
+		if err := s.sendDigest(d); err != nil {
+			return err
+		}
+	}
+}
+
+// sendDigest sends the digest to the active subscribers who have not received it yet, then marks it as sent
+func (s *Server) sendDigest(d *blog.Digest) error {
+	content := s.content()
+	var posts []*blog.Post
+	for _, p := range content.PostService.GetAllPosts() {
+		if t := publishedAt(p); t.After(d.From) && !t.After(d.To) {
+			posts = append(posts, p)
+		}
+	}
+
+	if len(posts) > 0 {
+		subscribers, err := s.SubscriberService.ActiveSubscribers()
+		if err != nil {
+			return err
+		}
+
+		for _, subscriber := range subscribers {
+			sent, err := s.DigestService.IsDigestSentTo(d.ID, subscriber.Email)
+			if err != nil {
+				return err
+			}
+			if sent {
+				continue
+			}
+
+			newsletter, err := content.Renderer.RenderNewsletter(posts, s.URL(), subscriber.Email)
+			if err != nil {
+				return err
+			}
+			// the newsletter is queued once per digest and subscriber, even if the recipient could not be recorded
+			if err := s.SMTPService.SendNewsletterEmail(subscriber.Email, fmt.Sprintf("digest-%d-%s", d.ID, subscriber.Email), newsletter); err != nil {
+				return err
+			}
+			if err := s.DigestService.AddDigestRecipient(d.ID, subscriber.Email, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	sentAt := time.Now()
+	d.Status = blog.DigestSent
+	d.Posts = len(posts)
+	d.SentAt = &sentAt
+	if err := s.DigestService.CompleteDigest(d); err != nil {
+		return err
+	}
+
+	s.logger.Info().Int64("digest", d.ID).Int("posts", d.Posts).Int("recipients", d.Recipients).Msg("newsletter digest sent")
+	return nil
+}
@@ -0,0 +1,203 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+	"github.com/quantonganh/blog/sqlite"
+)
+
+type fakeSMTPService struct {
+	newsletters map[string][]string
+	// failures is the number of newsletters which are sent before failing, no newsletter fails if it is negative
+	failures int
+	// keys are the keys of the queued newsletters, like the outbox a newsletter is queued once per key
+	keys map[string]bool
+}
+
+func (s *fakeSMTPService) SendConfirmationEmail(to, token string) error {
+	return nil
+}
+
+func (s *fakeSMTPService) SendThankYouEmail(to string) error {
+	return nil
+}
+
+func (s *fakeSMTPService) SendNewsletter(posts []*blog.Post) {}
+
+func (s *fakeSMTPService) SendNewsletterEmail(to, key string, newsletter *bytes.Buffer) error {
+	if s.failures == 0 {
+		return errors.New("connection refused")
+	}
+	s.failures--
+	if key != "" && s.keys[key] {
+		return nil
+	}
+	s.keys[key] = true
+	s.newsletters[to] = append(s.newsletters[to], newsletter.String())
+	return nil
+}
+
+// failingDigestService fails to record the next recipient if fail is set
+type failingDigestService struct {
+	blog.DigestService
+	fail bool
+}
+
+func (s *failingDigestService) AddDigestRecipient(id int64, email string, sentAt time.Time) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("database is locked")
+	}
+	return s.DigestService.AddDigestRecipient(id, email, sentAt)
+}
+
+func (s *fakeSMTPService) GenerateNewUUID() string {
+	return ""
+}
+
+func (s *fakeSMTPService) GetHMACSecret() string {
+	return ""
+}
+
+func (s *fakeSMTPService) Stop() error {
+	return nil
+}
+
+type fakeSubscriberService struct {
+	active []*blog.Subscriber
+}
+
+func (s *fakeSubscriberService) FindSubscriber(email string) (*blog.Subscriber, error) {
+	return nil, nil
+}
+
+func (s *fakeSubscriberService) ActiveSubscribers() ([]*blog.Subscriber, error) {
+	return s.active, nil
+}
+
+func (s *fakeSubscriberService) MarkBounced(email string) error {
+	return nil
+}
+
+func TestDigests(t *testing.T) {
+	var posts []*blog.Post
+	for _, date := range []string{"2020-12-31", "2021-01-05", "2021-01-07", "2021-01-10"} {
+		p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(fmt.Sprintf(`---
+title: Post of %s
+date: %s
+---
+Content.`, date, date)))
+		require.NoError(t, err)
+		posts = append(posts, p)
+	}
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Newsletter.Frequency = 7
+	config.Newsletter.HMAC.Secret = "secret"
+	config.Newsletter.From = "Blog <blog@example.com>"
+	ds, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ds.SearchService.CloseIndex()
+	})
+
+	db := sqlite.NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	smtpService := &fakeSMTPService{
+		newsletters: make(map[string][]string),
+		failures:    1,
+		keys:        make(map[string]bool),
+	}
+	ds.SMTPService = smtpService
+	digestService := &failingDigestService{DigestService: sqlite.NewDigestService(db)}
+	ds.DigestService = digestService
+	ds.SubscriberService = &fakeSubscriberService{
+		active: []*blog.Subscriber{
+			{Email: "foo@example.com", Status: blog.SubscriberActive},
+			{Email: "bar@example.com", Status: blog.SubscriberActive},
+		},
+	}
+
+	schedule, err := cron.ParseStandard("0 0 * * *")
+	require.NoError(t, err)
+	at := time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)
+	missed, err := ds.isDigestMissed(schedule, at)
+	require.NoError(t, err)
+	assert.False(t, missed)
+
+	// the first digest is interrupted after sending it to the first subscriber
+	require.Error(t, ds.sendDigests(at))
+	require.Len(t, smtpService.newsletters["foo@example.com"], 1)
+	assert.Empty(t, smtpService.newsletters["bar@example.com"])
+	missed, err = ds.isDigestMissed(schedule, at)
+	require.NoError(t, err)
+	assert.True(t, missed)
+
+	// it is interrupted again after queuing it to the second subscriber, before recording the recipient
+	smtpService.failures = -1
+	digestService.fail = true
+	require.Error(t, ds.sendDigests(at))
+	require.Len(t, smtpService.newsletters["bar@example.com"], 1)
+
+	// it is resumed without sending it again to the subscribers
+	require.NoError(t, ds.sendDigests(at))
+	require.Len(t, smtpService.newsletters["foo@example.com"], 1)
+	require.Len(t, smtpService.newsletters["bar@example.com"], 1)
+	newsletter := smtpService.newsletters["bar@example.com"][0]
+	assert.Contains(t, newsletter, "Post of 2021-01-05")
+	assert.Contains(t, newsletter, "Post of 2021-01-07")
+	assert.NotContains(t, newsletter, "Post of 2020-12-31")
+	assert.NotContains(t, newsletter, "Post of 2021-01-10")
+	msg, err := mail.ReadMessage(strings.NewReader(newsletter))
+	require.NoError(t, err)
+	assert.Equal(t, "bar@example.com", msg.Header.Get("To"))
+
+	last, err := ds.DigestService.LastDigest()
+	require.NoError(t, err)
+	assert.Equal(t, blog.DigestSent, last.Status)
+	assert.Equal(t, 2, last.Posts)
+	assert.Equal(t, 2, last.Recipients)
+
+	require.NoError(t, ds.sendDigests(at))
+	require.Len(t, smtpService.newsletters["foo@example.com"], 1)
+
+	// the next digest starts where the last one ends, even if a scheduled digest was missed
+	missed, err = ds.isDigestMissed(schedule, at.Add(12*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, missed)
+	missed, err = ds.isDigestMissed(schedule, at.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.True(t, missed)
+
+	at = at.AddDate(0, 0, 7)
+	require.NoError(t, ds.sendDigests(at))
+	require.Len(t, smtpService.newsletters["foo@example.com"], 2)
+	newsletter = smtpService.newsletters["foo@example.com"][1]
+	assert.Contains(t, newsletter, "Post of 2021-01-10")
+	assert.NotContains(t, newsletter, "Post of 2021-01-07")
+
+	last, err = ds.DigestService.LastDigest()
+	require.NoError(t, err)
+	assert.True(t, at.AddDate(0, 0, -7).Equal(last.From))
+	assert.True(t, at.Equal(last.To))
+}
@@ -30,13 +30,13 @@ func (s *Server) Error(fn appHandler) http.HandlerFunc {
 		if !ok {
 			sentry.CaptureException(err)
 			w.WriteHeader(http.StatusInternalServerError)
-			_ = s.Renderer.RenderResponseMessage(w, contextualClassDanger, errOops)
+			_ = s.content().Renderer.RenderResponseMessage(w, contextualClassDanger, errOops)
 			return
 		}
 
 		status, _ := clientError.Headers()
 		w.WriteHeader(status)
-		_ = s.Renderer.RenderResponseMessage(w, contextualClassWarning, clientError.Body())
+		_ = s.content().Renderer.RenderResponseMessage(w, contextualClassWarning, clientError.Body())
 	}
 }
 
@@ -0,0 +1,258 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+	"github.com/quantonganh/blog/ui"
+)
+
+const indexFile = "index.html"
+
+var pageLinkRegexp = regexp.MustCompile(`href="([^"?]*)\?p=(\d+)"`)
+
+// Export renders every route of the server and writes the output into outputDir,
+// so that the blog can be served from plain static file hosting
+func (s *Server) Export(outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create output directory %s", outputDir)
+	}
+
+	postService := s.content().PostService
+	posts := postService.GetAllPosts()
+	if err := s.exportPages(outputDir, "/", len(posts)); err != nil {
+		return err
+	}
+
+	for _, p := range append(posts[:len(posts):len(posts)], postService.GetUnlistedPosts()...) {
+		if err := s.exportPost(outputDir, p); err != nil {
+			return err
+		}
+	}
+
+	for tag, count := range postService.GetPostsPerTag() {
+		if err := s.exportPages(outputDir, "/tags/"+tag, count); err != nil {
+			return err
+		}
+		if err := s.exportFeeds(outputDir, "/tags/"+tag); err != nil {
+			return err
+		}
+	}
+
+	for category, postsByCategory := range postService.GetAllCategories() {
+		if err := s.exportPages(outputDir, "/categories/"+category, len(postsByCategory)); err != nil {
+			return err
+		}
+		if err := s.exportFeeds(outputDir, "/categories/"+category); err != nil {
+			return err
+		}
+	}
+
+	for series, parts := range postService.GetAllSeries() {
+		if err := s.exportPages(outputDir, "/series/"+series, len(parts)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.exportArchives(outputDir, postService); err != nil {
+		return err
+	}
+
+	for _, p := range []string{"/tags", "/series", "/archives", "/photos", "/sitemap.xml", "/favicon.ico"} {
+		if err := s.exportRoute(outputDir, p, p); err != nil {
+			return err
+		}
+	}
+
+	if err := s.exportFeeds(outputDir, "/"); err != nil {
+		return err
+	}
+
+	if err := exportFS(ui.StaticFS, "static", outputDir, nil); err != nil {
+		return err
+	}
+
+	fsys := s.ContentSource.FS()
+	if _, err := fs.Stat(fsys, "."); err == nil {
+		isImage := func(name string) bool {
+			return hasSuffix(strings.ToLower(name), []string{"jpg", "jpeg", "png", "gif"})
+		}
+		if err := exportFS(fsys, ".", outputDir, isImage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) exportPost(outputDir string, p *blog.Post) error {
+	if err := s.exportRoute(outputDir, p.URI, p.URI); err != nil {
+		return err
+	}
+
+	// links such as /about do not have the extension
+	route := strings.TrimSuffix(p.URI, markdown.Extension)
+	return s.exportRoute(outputDir, route, route)
+}
+
+func (s *Server) exportArchives(outputDir string, postService blog.PostService) error {
+	postsByMonth := postService.GetPostsByMonth()
+	for _, year := range postService.GetYears() {
+		if err := s.exportPages(outputDir, "/"+year, len(postService.GetPostsByYear(year))); err != nil {
+			return err
+		}
+
+		for _, month := range postService.GetMonthsInYear()[year] {
+			if err := s.exportPages(outputDir, path.Join("/", year, month), len(postsByMonth[year][month])); err != nil {
+				return err
+			}
+		}
+	}
+
+	days := make(map[string]int)
+	for _, p := range postService.GetAllPosts() {
+		days[path.Join("/", p.Date.GetYear(), p.Date.GetMonth(), p.Date.GetDay())]++
+	}
+	for day, count := range days {
+		if err := s.exportPages(outputDir, day, count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) exportFeeds(outputDir, prefix string) error {
+	for _, name := range []string{"rss.xml", "atom.xml", "feed.json"} {
+		route := path.Join(prefix, name)
+		if err := s.exportRoute(outputDir, route, route); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportPages exports a listing route and all of its pages, page n is written to <route>/page/<n>/index.html
+func (s *Server) exportPages(outputDir, route string, numberOfPosts int) error {
+	if err := s.exportRoute(outputDir, route, route); err != nil {
+		return err
+	}
+
+	postsPerPage, err := getPostsPerPage()
+	if err != nil {
+		return err
+	}
+
+	for page := 2; (page-1)*postsPerPage < numberOfPosts; page++ {
+		target := path.Join(route, "page", strconv.Itoa(page)) + "/"
+		if err := s.exportRoute(outputDir, fmt.Sprintf("%s?p=%d", route, page), target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportRoute renders the given URL and writes the response to the file corresponding to the target path
+func (s *Server) exportRoute(outputDir, rawURL, target string) error {
+	routePath, query, _ := strings.Cut(rawURL, "?")
+	u := &url.URL{
+		Path:     routePath,
+		RawQuery: query,
+	}
+	req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		return errors.Errorf("failed to export %s: unexpected status code %d", rawURL, rr.Code)
+	}
+
+	body := rr.Body.Bytes()
+	if strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		body = []byte(rewritePageLinks(string(body)))
+	}
+
+	name := filepath.Join(outputDir, filepath.FromSlash(target))
+	if path.Ext(target) == "" || strings.HasSuffix(target, "/") {
+		name = filepath.Join(name, indexFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %s", name)
+	}
+
+	return os.WriteFile(name, body, 0644)
+}
+
+// rewritePageLinks replaces pagination query strings with the paths written by exportPages
+func rewritePageLinks(body string) string {
+	return pageLinkRegexp.ReplaceAllStringFunc(body, func(m string) string {
+		parts := pageLinkRegexp.FindStringSubmatch(m)
+		return fmt.Sprintf(`href="%s/"`, path.Join(parts[1], "page", parts[2]))
+	})
+}
+
+// exportFS copies files from root in fsys into outputDir, keeping their relative paths
+func exportFS(fsys fs.FS, root, outputDir string, match func(name string) bool) error {
+	var names []string
+	if err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (match != nil && !match(name)) {
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to walk %s", root)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := copyFile(fsys, name, filepath.Join(outputDir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(fsys fs.FS, name, dst string) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", name)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", dst)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, src); err != nil {
+		return errors.Wrapf(err, "failed to copy %s", name)
+	}
+
+	return nil
+}
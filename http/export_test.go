@@ -0,0 +1,46 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+	require.NoError(t, s.Export(outputDir))
+
+	for _, name := range []string{
+		"index.html",
+		"2019/09/19/test.md",
+		"2019/09/19/test/index.html",
+		"2019/09/19/index.html",
+		"2019/09/index.html",
+		"2019/index.html",
+		"tags/index.html",
+		"tags/test/index.html",
+		"categories/Du lịch/index.html",
+		"archives/index.html",
+		"photos/index.html",
+		"sitemap.xml",
+		"rss.xml",
+		"favicon.ico",
+		"static/css/index.css",
+	} {
+		_, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(name)))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestRewritePageLinks(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `<a class="page-link" href="/page/2/">2</a>`, rewritePageLinks(`<a class="page-link" href="/?p=2">2</a>`))
+	assert.Equal(t, `<a class="page-link" href="/tags/go/page/3/">3</a>`, rewritePageLinks(`<a class="page-link" href="/tags/go?p=3">3</a>`))
+	assert.Equal(t, `<a class="page-link" href="/tags/go">1</a>`, rewritePageLinks(`<a class="page-link" href="/tags/go">1</a>`))
+}
@@ -0,0 +1,251 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	rssFormat  = "rss"
+	atomFormat = "atom"
+	jsonFormat = "json"
+)
+
+var feedContentTypes = map[string]string{
+	rssFormat:  "application/rss+xml; charset=utf-8",
+	atomFormat: "application/atom+xml; charset=utf-8",
+	jsonFormat: "application/feed+json; charset=utf-8",
+}
+
+// feed represents the posts of a feed and its metadata, independent of the format
+type feed struct {
+	title       string
+	description string
+	author      string
+	link        string
+	feedURL     string
+	updated     time.Time
+	posts       []*blog.Post
+}
+
+func (s *Server) feedHandler(config *blog.Config, format string) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		postService := s.content().PostService
+
+		title := config.Site.Title
+		if title == "" {
+			title = r.Host
+		}
+		f := &feed{
+			title:       title,
+			description: config.Site.Params.Description,
+			author:      config.Site.Params.Author,
+			link:        s.URL(),
+			feedURL:     s.URL() + r.URL.Path,
+		}
+
+		vars := mux.Vars(r)
+		switch {
+		case vars["tagName"] != "":
+			f.title = fmt.Sprintf("%s - %s", f.title, vars["tagName"])
+			f.link = fmt.Sprintf("%s/tags/%s", s.URL(), vars["tagName"])
+			f.posts = postService.GetPostsByTag(vars["tagName"])
+		case vars["categoryName"] != "":
+			f.title = fmt.Sprintf("%s - %s", f.title, vars["categoryName"])
+			f.link = fmt.Sprintf("%s/categories/%s", s.URL(), vars["categoryName"])
+			f.posts = postService.GetPostsByCategory(vars["categoryName"])
+		default:
+			f.posts = postService.GetAllPosts()
+		}
+		if len(f.posts) == 0 && (vars["tagName"] != "" || vars["categoryName"] != "") {
+			return &Error{
+				Message: "feed not found",
+				Status:  http.StatusNotFound,
+			}
+		}
+
+		for _, p := range f.posts {
+			if t := publishedAt(p); t.After(f.updated) {
+				f.updated = t
+			}
+		}
+
+		var (
+			body []byte
+			err  error
+		)
+		switch format {
+		case atomFormat:
+			body, err = s.atom(f)
+		case jsonFormat:
+			body, err = s.jsonFeed(f)
+		default:
+			body, err = s.rss(f)
+		}
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.Sum256(body)
+		w.Header().Set("Content-Type", feedContentTypes[format])
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])))
+		http.ServeContent(w, r, "", f.updated, bytes.NewReader(body))
+
+		return nil
+	}
+}
+
+// publishedAt returns the time the post went live, which is later than its date for scheduled posts
+func publishedAt(p *blog.Post) time.Time {
+	if p.PublishDate.After(p.Date.Time) {
+		return p.PublishDate.Time
+	}
+	return p.Date.Time
+}
+
+func (s *Server) rss(f *feed) ([]byte, error) {
+	rss := blog.RSS{
+		Version:          "2.0",
+		ContentNamespace: blog.RSSContentNamespace,
+		DCNamespace:      blog.RSSDublinCoreNamespace,
+		Channel: blog.RSSChannel{
+			Title:         f.title,
+			Link:          f.link,
+			Description:   f.description,
+			LastBuildDate: f.updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, p := range f.posts {
+		link := fmt.Sprintf("%s%s", s.URL(), p.URI)
+		rss.Channel.Items = append(rss.Channel.Items, blog.RSSItem{
+			Title:       p.Title,
+			Link:        link,
+			GUID:        link,
+			Description: p.Description,
+			Content: blog.RSSContent{
+				Content: string(p.Content),
+			},
+			Creator:    f.author,
+			Categories: append(p.Categories[:len(p.Categories):len(p.Categories)], p.Tags...),
+			PubDate:    publishedAt(p).Format(time.RFC1123Z),
+		})
+	}
+
+	output, err := xml.MarshalIndent(rss, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create RSS")
+	}
+
+	return append([]byte(xml.Header), output...), nil
+}
+
+func (s *Server) atom(f *feed) ([]byte, error) {
+	atom := blog.AtomFeed{
+		Xmlns:   blog.AtomNamespace,
+		Title:   f.title,
+		ID:      f.link,
+		Updated: f.updated.Format(time.RFC3339),
+		Links: []blog.AtomLink{
+			{
+				Href: f.link,
+				Rel:  "alternate",
+			},
+			{
+				Href: f.feedURL,
+				Rel:  "self",
+			},
+		},
+	}
+	if f.author != "" {
+		atom.Author = &blog.AtomAuthor{
+			Name: f.author,
+		}
+	}
+	for _, p := range f.posts {
+		link := fmt.Sprintf("%s%s", s.URL(), p.URI)
+		entry := blog.AtomEntry{
+			Lang:      p.Lang,
+			Title:     p.Title,
+			ID:        link,
+			Updated:   publishedAt(p).Format(time.RFC3339),
+			Published: publishedAt(p).Format(time.RFC3339),
+			Links: []blog.AtomLink{
+				{
+					Href: link,
+					Rel:  "alternate",
+				},
+			},
+			Summary: &blog.AtomText{
+				Type: "text",
+				Body: p.Description,
+			},
+			Content: &blog.AtomText{
+				Type: "html",
+				Body: string(p.Content),
+			},
+		}
+		for _, term := range append(p.Categories[:len(p.Categories):len(p.Categories)], p.Tags...) {
+			entry.Categories = append(entry.Categories, blog.AtomCategory{
+				Term: term,
+			})
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	output, err := xml.MarshalIndent(atom, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Atom feed")
+	}
+
+	return append([]byte(xml.Header), output...), nil
+}
+
+func (s *Server) jsonFeed(f *feed) ([]byte, error) {
+	jsonFeed := blog.JSONFeed{
+		Version:     blog.JSONFeedVersion,
+		Title:       f.title,
+		HomePageURL: f.link,
+		FeedURL:     f.feedURL,
+		Description: f.description,
+		Items:       []blog.JSONFeedItem{},
+	}
+	if f.author != "" {
+		jsonFeed.Authors = []blog.JSONFeedAuthor{
+			{
+				Name: f.author,
+			},
+		}
+	}
+	for _, p := range f.posts {
+		link := fmt.Sprintf("%s%s", s.URL(), p.URI)
+		published := publishedAt(p)
+		jsonFeed.Items = append(jsonFeed.Items, blog.JSONFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         p.Title,
+			ContentHTML:   string(p.Content),
+			Summary:       p.Description,
+			DatePublished: &published,
+			Tags:          append(p.Categories[:len(p.Categories):len(p.Categories)], p.Tags...),
+			Language:      p.Lang,
+		})
+	}
+
+	output, err := json.MarshalIndent(jsonFeed, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create JSON feed")
+	}
+
+	return output, nil
+}
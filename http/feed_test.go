@@ -0,0 +1,99 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/feeds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestRSSHandler(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/rss.xml", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "<content:encoded><![CDATA[<p>Test.</p>\n]]></content:encoded>")
+	var rss *feeds.RssFeedXml
+	require.NoError(t, xml.NewDecoder(rr.Body).Decode(&rss))
+	assert.Equal(t, 1, len(rss.Channel.Items))
+	assert.Equal(t, "http://localhost/2019/09/19/test.md", rss.Channel.Items[0].Link)
+}
+
+func TestAtomHandler(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/tags/test/atom.xml", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	var atom *blog.AtomFeed
+	require.NoError(t, xml.NewDecoder(rr.Body).Decode(&atom))
+	require.Equal(t, 1, len(atom.Entries))
+	assert.Equal(t, "http://localhost/2019/09/19/test.md", atom.Entries[0].ID)
+	assert.Equal(t, []blog.AtomCategory{{Term: "Du lịch"}, {Term: "test"}}, atom.Entries[0].Categories)
+}
+
+func TestJSONFeedHandler(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/categories/Du%20lịch/feed.json", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/feed+json; charset=utf-8", rr.Header().Get("Content-Type"))
+	var jsonFeed *blog.JSONFeed
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&jsonFeed))
+	assert.Equal(t, blog.JSONFeedVersion, jsonFeed.Version)
+	require.Equal(t, 1, len(jsonFeed.Items))
+	assert.Equal(t, "http://localhost/2019/09/19/test.md", jsonFeed.Items[0].URL)
+	assert.Equal(t, "<p>Test.</p>\n", jsonFeed.Items[0].ContentHTML)
+	assert.Equal(t, "en", jsonFeed.Items[0].Language)
+}
+
+func TestFeedConditionalRequest(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/atom.xml", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "Thu, 19 Sep 2019 14:48:39 GMT", rr.Header().Get("Last-Modified"))
+
+	rr = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/atom.xml", nil)
+	assert.NoError(t, err)
+	request.Header.Set("If-None-Match", etag)
+	s.router.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestFeedNotFound(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/tags/unknown/rss.xml", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
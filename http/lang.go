@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/blog/markdown"
+)
+
+// languageNames are the names of the supported languages, written in the language itself
+var languageNames = map[string]string{
+	markdown.English:    "English",
+	markdown.Vietnamese: "Tiếng Việt",
+}
+
+// langPrefix is the path prefix of the listings filtered by language, e.g. /vi/tags/go
+var langPrefix = "/{lang:" + strings.Join([]string{markdown.English, markdown.Vietnamese}, "|") + "}"
+
+// getLang returns the language from the path prefix or the lang query parameter, empty means all the languages
+func getLang(r *http.Request) (string, error) {
+	lang, ok := mux.Vars(r)["lang"]
+	if !ok {
+		lang = r.FormValue("lang")
+	}
+	if _, ok := languageNames[lang]; lang != "" && !ok {
+		return "", NewError(nil, http.StatusBadRequest, "unsupported language: "+lang)
+	}
+
+	return lang, nil
+}
+
+// toLanguageName returns the name of the language, the code itself if the language is not supported
+func toLanguageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return lang
+}
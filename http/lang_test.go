@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+func TestTranslations(t *testing.T) {
+	t.Parallel()
+
+	var posts []*blog.Post
+	for _, content := range []string{
+		`---
+title: Hello
+date: 2021-01-01
+tags: [greeting]
+translationKey: hello
+---
+Hello, this is my first post.`,
+		`---
+title: Chao
+date: 2021-01-02
+tags: [greeting]
+translationKey: hello
+---
+Xin chào, đây là bài viết đầu tiên của tôi.`,
+	} {
+		p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(content))
+		require.NoError(t, err)
+		posts = append(posts, p)
+	}
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		ss.router.ServeHTTP(rr, request)
+		return rr
+	}
+
+	post := get("/2021/01/01/hello").Body.String()
+	assert.Contains(t, post, `<html lang="en">`)
+	assert.Contains(t, post, `href="/2021/01/02/chao.md" hreflang="vi"`)
+
+	for _, url := range []string{"/vi/", "/vi/tags/greeting", "/tags/greeting?lang=vi"} {
+		body := get(url).Body.String()
+		assert.Contains(t, body, "/2021/01/02/chao.md", url)
+		assert.NotContains(t, body, "/2021/01/01/hello.md", url)
+	}
+	assert.Equal(t, http.StatusBadRequest, get("/?lang=fr").Code)
+
+	var sitemap *blog.Sitemap
+	require.NoError(t, xml.NewDecoder(get("/sitemap.xml").Body).Decode(&sitemap))
+	require.Equal(t, 3, len(sitemap.URLs))
+	assert.Equal(t, []blog.AlternateLink{
+		{Rel: "alternate", HrefLang: "en", Href: "http://localhost/2021/01/01/hello.md"},
+		{Rel: "alternate", HrefLang: "vi", Href: "http://localhost/2021/01/02/chao.md"},
+	}, sitemap.URLs[1].Alternates)
+}
+
+func TestToLanguageName(t *testing.T) {
+	assert.Equal(t, "Tiếng Việt", toLanguageName(markdown.Vietnamese))
+	assert.Equal(t, "fr", toLanguageName("fr"))
+}
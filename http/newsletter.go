@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+
+	"github.com/quantonganh/blog"
 )
 
 const (
@@ -19,18 +23,69 @@ const (
 	confirmationMessage       = "A confirmation email has been sent to %s. Click the link in the email to confirm and activate your subscription. Check your spam folder if you don't see it within a couple of minutes."
 	thankyouMessage           = "Thank you for subscribing to this blog."
 	pendingMessage            = "Your subscription status is pending. Please click the confirmation link in your email."
+	pendingResendMessage      = "Your subscription status is pending. Please click the confirmation link in your email, or request a new one below."
+	expiredMessage            = "The confirmation link has expired. Enter your email to receive a new one."
+	tooManyAttemptsMessage    = "Too many subscription attempts. Please try again later."
+	invalidFormMessage        = "The subscription form has expired. Please reload the page and try again."
 	alreadySubscribedMessage  = "You had been subscribed to this blog already."
 	notFoundMessage           = "Cannot found email: %s"
 	unsubscribeMessage        = "You've been successfully unsubscribed from our blog updates. If this was unintentional or you change your mind, feel free to resubscribe anytime."
 	invalidUnsubscribeMessage = "Either email or hash is invalid"
+	newsletterDisabledMessage = "The newsletter is not available."
 )
 
+// requireNewsletter answers not found if no newsletter service is set: neither newsletter.baseURL nor the sqlite store
+// is configured
+func (s *Server) requireNewsletter(h appHandler) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if s.NewsletterService == nil {
+			return NewError(nil, http.StatusNotFound, newsletterDisabledMessage)
+		}
+		return h(w, r)
+	}
+}
+
 func (s *Server) subscribeHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.subscribe(w, r, false)
+}
+
+// formTokenHandler signs a new subscription form, the token is fetched by the pages when they are loaded
+// so that it is never cached with them
+func (s *Server) formTokenHandler(w http.ResponseWriter, r *http.Request) error {
+	token, err := newFormToken(s.config.Newsletter.HMAC.Secret, time.Now())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	return json.NewEncoder(w).Encode(map[string]string{
+		"token": token,
+	})
+}
+
+// resendHandler sends a new confirmation email to a pending subscriber, only the sqlite store supports it
+func (s *Server) resendHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.subscribe(w, r, true)
+}
+
+// subscribe checks the honeypot field, the attempts of the IP address, the form token and the attempts of the email
+// before asking the newsletter service to send the confirmation email
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request, resend bool) error {
 	hpEmail := r.FormValue("email")
 	if hpEmail != "" {
 		return NewError(errors.New("better luck next time, bot!"), http.StatusBadRequest, "Congratulations! You've stumbled into our Honeypot field")
 	}
 
+	ip := s.clientIP(r)
+	if !s.ipLimiter.allow(ip, time.Now()) {
+		return NewError(errors.Errorf("too many subscription attempts from %s", ip), http.StatusTooManyRequests, tooManyAttemptsMessage)
+	}
+
+	if err := s.formTokens.verify(r.FormValue("token"), time.Now()); err != nil {
+		return NewError(err, http.StatusBadRequest, invalidFormMessage)
+	}
+
 	email := r.FormValue("email82244417f9")
 	log := zerolog.Ctx(r.Context())
 	log.UpdateContext(func(c zerolog.Context) zerolog.Context {
@@ -41,10 +96,17 @@ func (s *Server) subscribeHandler(w http.ResponseWriter, r *http.Request) error
 		return NewError(err, http.StatusBadRequest, "Invalid email address.")
 	}
 
-	subsReq := map[string]string{
+	if !s.emailLimiter.allow(strings.ToLower(a.Address), time.Now()) {
+		return NewError(errors.Errorf("too many subscription attempts for %s", a.Address), http.StatusTooManyRequests, tooManyAttemptsMessage)
+	}
+
+	subsReq := map[string]interface{}{
 		"url":   s.URL(),
 		"email": a.Address,
 	}
+	if resend {
+		subsReq["resend"] = true
+	}
 	body, err := json.Marshal(subsReq)
 	if err != nil {
 		return err
@@ -58,28 +120,25 @@ func (s *Server) subscribeHandler(w http.ResponseWriter, r *http.Request) error
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassSuccess, fmt.Sprintf(confirmationMessage, a.Address)); err != nil {
-			return err
-		}
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassSuccess, fmt.Sprintf(confirmationMessage, a.Address))
 	case http.StatusUnauthorized:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassWarning, pendingMessage); err != nil {
-			return err
+		if !s.canResendConfirmation() {
+			return s.content().Renderer.RenderResponseMessage(w, contextualClassWarning, pendingMessage)
 		}
+		return s.content().Renderer.RenderResendConfirmation(w, contextualClassWarning, pendingResendMessage, a.Address)
 	case http.StatusNotFound:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassWarning, fmt.Sprintf(notFoundMessage, a.Address)); err != nil {
-			return err
-		}
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassWarning, fmt.Sprintf(notFoundMessage, a.Address))
 	case http.StatusConflict:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassWarning, alreadySubscribedMessage); err != nil {
-			return err
-		}
-	case http.StatusInternalServerError:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassDanger, errOops); err != nil {
-			return err
-		}
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassWarning, alreadySubscribedMessage)
+	default:
+		return responseError(resp)
 	}
+}
 
-	return nil
+// canResendConfirmation reports whether the newsletter store sends a new confirmation email to a pending subscriber,
+// the newsletter service does not
+func (s *Server) canResendConfirmation() bool {
+	return s.config.Newsletter.Store == blog.NewsletterStoreSQLite
 }
 
 func (s *Server) confirmHandler(w http.ResponseWriter, r *http.Request) error {
@@ -94,27 +153,17 @@ func (s *Server) confirmHandler(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer resp.Body.Close()
 
-	statusCode := resp.StatusCode
-	if statusCode == http.StatusOK {
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassSuccess, thankyouMessage); err != nil {
-			return err
-		}
-		return nil
-	} else if 400 <= statusCode && statusCode <= 499 {
-		var m map[string]string
-		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
-			return err
-		}
-		return &Error{
-			Status:  statusCode,
-			Message: m["message"],
-		}
-	} else {
-		var m map[string]string
-		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
-			return err
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassSuccess, thankyouMessage)
+	case http.StatusGone:
+		if !s.canResendConfirmation() {
+			return responseError(resp)
 		}
-		return errors.New(m["error"])
+		w.WriteHeader(http.StatusGone)
+		return s.content().Renderer.RenderResendConfirmation(w, contextualClassWarning, expiredMessage, "")
+	default:
+		return responseError(resp)
 	}
 }
 
@@ -131,14 +180,25 @@ func (s *Server) unsubscribeHandler(w http.ResponseWriter, r *http.Request) erro
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassSuccess, unsubscribeMessage); err != nil {
-			return err
-		}
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassSuccess, unsubscribeMessage)
 	case http.StatusBadRequest:
-		if err := s.Renderer.RenderResponseMessage(w, contextualClassWarning, invalidUnsubscribeMessage); err != nil {
-			return err
+		return s.content().Renderer.RenderResponseMessage(w, contextualClassWarning, invalidUnsubscribeMessage)
+	default:
+		return responseError(resp)
+	}
+}
+
+// responseError returns the error of an unexpected response of the newsletter service,
+// the message of a 4xx response is rendered as is, the other responses are rendered as an internal error
+func responseError(resp *http.Response) error {
+	var m map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&m)
+	if 400 <= resp.StatusCode && resp.StatusCode <= 499 && m["message"] != "" {
+		return &Error{
+			Status:  resp.StatusCode,
+			Message: m["message"],
 		}
 	}
 
-	return nil
+	return errors.Errorf("unexpected response from the newsletter service: %s %s", resp.Status, m["error"])
 }
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+func newsletterSubject(config *blog.Config) string {
+	name := config.Newsletter.Product.Name
+	if name == "" {
+		name = config.Site.Title
+	}
+
+	return fmt.Sprintf("New posts on %s", name)
+}
+
+// absoluteURLs resolves the links and the sources of the images against base, since a mail client has no base URL
+func absoluteURLs(html []byte, base *url.URL) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse HTML")
+	}
+
+	for _, attr := range []string{"href", "src"} {
+		doc.Find("[" + attr + "]").Each(func(_ int, s *goquery.Selection) {
+			value, _ := s.Attr(attr)
+			u, err := url.Parse(value)
+			if err != nil {
+				return
+			}
+			s.SetAttr(attr, base.ResolveReference(u).String())
+		})
+	}
+
+	result, err := doc.Html()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(result), nil
+}
+
+// newsletterText returns the plain text alternative of the newsletter: the title, the URL, the date and the summary of each post
+func newsletterText(posts []*blog.Post, base *url.URL, unsubscribeURL string) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, p := range posts {
+		summary, err := htmlToText(string(p.Summary))
+		if err != nil {
+			return nil, err
+		}
+		if summary == "" {
+			summary = p.Description
+		}
+
+		u, err := url.Parse(p.URI)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse URI %s", p.URI)
+		}
+
+		fmt.Fprintf(&buf, "%s\n%s\n%s\n\n", p.Title, base.ResolveReference(u), blog.ToISODate(p.Date))
+		if summary != "" {
+			fmt.Fprintf(&buf, "%s\n\n", summary)
+		}
+		buf.WriteString("---\n\n")
+	}
+	fmt.Fprintf(&buf, "Unsubscribe: %s\n", unsubscribeURL)
+
+	return buf.Bytes(), nil
+}
+
+// htmlToText returns the text of the blocks of an HTML fragment, separated by blank lines, the images are replaced by their alt text
+func htmlToText(html string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse HTML")
+	}
+
+	doc.Find("img").Each(func(_ int, s *goquery.Selection) {
+		alt, _ := s.Attr("alt")
+		s.ReplaceWithHtml(template.HTMLEscapeString(alt))
+	})
+
+	var blocks []string
+	doc.Find("body").Children().Each(func(_ int, s *goquery.Selection) {
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			blocks = append(blocks, text)
+		}
+	})
+
+	return strings.Join(blocks, "\n\n"), nil
+}
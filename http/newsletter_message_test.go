@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+	"github.com/quantonganh/blog/pkg/hash"
+)
+
+func TestRenderNewsletter(t *testing.T) {
+	p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(`---
+title: Hello
+date: 2021-01-05
+description: Say hello
+tags:
+  - greeting
+---
+The first paragraph.
+
+The second paragraph with an ![image](/2021/01/05/hello.png).`))
+	require.NoError(t, err)
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Site.BaseURL = "https://blog.example.com"
+	config.Newsletter.From = "Blog <blog@example.com>"
+	config.Newsletter.Product.Name = "Blog"
+	config.Newsletter.HMAC.Secret = "secret"
+	ns, err := NewServer(zerolog.Nop(), config, []*blog.Post{p})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ns.SearchService.CloseIndex()
+	})
+
+	buf, err := ns.RenderNewsletter([]*blog.Post{p}, "http://localhost", "foo@example.com")
+	require.NoError(t, err)
+	msg, err := mail.ReadMessage(buf)
+	require.NoError(t, err)
+
+	hashValue, err := hash.ComputeHmac256("foo@example.com", "secret")
+	require.NoError(t, err)
+	unsubscribeURL := "https://blog.example.com/unsubscribe?email=foo%40example.com&hash=" + strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(hashValue)
+	assert.Equal(t, "foo@example.com", msg.Header.Get("To"))
+	assert.Equal(t, "New posts on Blog", msg.Header.Get("Subject"))
+	assert.Equal(t, "<"+unsubscribeURL+">", msg.Header.Get("List-Unsubscribe"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", msg.Header.Get("List-Unsubscribe-Post"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := make(map[string]string)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "quoted-printable", part.Header.Get("Content-Transfer-Encoding"))
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(t, err)
+		mediaType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		parts[mediaType] = string(body)
+	}
+	require.Len(t, parts, 2)
+
+	assert.Equal(t, `Hello
+https://blog.example.com/2021/01/05/hello.md
+2021-01-05
+
+The first paragraph.
+
+The second paragraph with an image.
+
+---
+
+Unsubscribe: `+unsubscribeURL+"\n", strings.ReplaceAll(parts["text/plain"], "\r\n", "\n"))
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(parts["text/html"]))
+	require.NoError(t, err)
+	assert.Zero(t, doc.Find("style").Length())
+	var links []string
+	doc.Find("a").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		links = append(links, href)
+	})
+	assert.Equal(t, []string{
+		"https://blog.example.com/2021/01/05/hello.md",
+		"https://blog.example.com/tags/greeting",
+		unsubscribeURL,
+	}, links)
+	style, _ := doc.Find("a.tag").Attr("style")
+	assert.Equal(t, "color: #0d6efd; text-decoration: none; margin-right: 8px", style)
+	style, _ = doc.Find("body").Attr("style")
+	assert.Contains(t, style, "font-family:")
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+type fakeNewsletterService struct {
+	status   int
+	body     string
+	requests []map[string]interface{}
+}
+
+func (s *fakeNewsletterService) Subscribe(r *http.Request, body io.Reader) (*http.Response, error) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return nil, err
+	}
+	s.requests = append(s.requests, req)
+	return s.response(), nil
+}
+
+func (s *fakeNewsletterService) Confirm(r *http.Request, token string) (*http.Response, error) {
+	return s.response(), nil
+}
+
+func (s *fakeNewsletterService) Unsubscribe(r *http.Request, email, hash string) (*http.Response, error) {
+	return s.response(), nil
+}
+
+func (s *fakeNewsletterService) response() *http.Response {
+	return &http.Response{
+		StatusCode: s.status,
+		Status:     http.StatusText(s.status),
+		Body:       io.NopCloser(strings.NewReader(s.body)),
+	}
+}
+
+func TestSubscribeHandler(t *testing.T) {
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Newsletter.Store = blog.NewsletterStoreSQLite
+	config.Newsletter.HMAC.Secret = "secret"
+	config.Newsletter.Subscription.IPLimit = 5
+	config.Newsletter.Subscription.EmailLimit = 3
+	config.Newsletter.Subscription.Window = time.Hour
+	config.Newsletter.Subscription.MaxFormAge = time.Hour
+	ns, err := NewServer(zerolog.Nop(), config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ns.SearchService.CloseIndex()
+	})
+	newsletterService := &fakeNewsletterService{status: http.StatusOK}
+	ns.NewsletterService = newsletterService
+	// the forms are submitted as soon as their token is fetched
+	ns.formTokens = newFormTokens(config.Newsletter.HMAC.Secret, 0, time.Hour)
+
+	// the token is fetched by the page, it is not rendered with it
+	rr := httptest.NewRecorder()
+	ns.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	doc, err := goquery.NewDocumentFromReader(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, 1, doc.Find(`form[action="/subscriptions"] input[name="token"]`).Length())
+	assert.Empty(t, doc.Find(`form[action="/subscriptions"] input[name="token"]`).AttrOr("value", ""))
+
+	formToken := func() string {
+		rr := httptest.NewRecorder()
+		ns.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/subscriptions/token", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		var resp map[string]string
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.NotEmpty(t, resp["token"])
+		return resp["token"]
+	}
+	post := func(path, ip string, form url.Values) (int, *goquery.Document) {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		ns.router.ServeHTTP(rr, r)
+		doc, err := goquery.NewDocumentFromReader(rr.Body)
+		require.NoError(t, err)
+		return rr.Code, doc
+	}
+	subscribe := func(ip, email, token string) (int, *goquery.Document) {
+		return post("/subscriptions", ip, url.Values{"email82244417f9": {email}, "token": {token}})
+	}
+	message := func(doc *goquery.Document) string {
+		return strings.TrimSpace(doc.Find(".alert").Text())
+	}
+
+	token := formToken()
+	status, doc := subscribe("192.0.2.1", "foo@example.com", token)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, message(doc), "A confirmation email has been sent to foo@example.com")
+
+	// a submitted form cannot be replayed
+	status, doc = subscribe("192.0.2.1", "bar@example.com", token)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, invalidFormMessage, message(doc))
+	status, _ = subscribe("192.0.2.1", "bar@example.com", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// a pending subscriber can ask for a new confirmation email
+	newsletterService.status = http.StatusUnauthorized
+	status, doc = subscribe("192.0.2.1", "foo@example.com", formToken())
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, pendingResendMessage, message(doc))
+	email, _ := doc.Find(`form[action="/subscriptions/resend"] input[name="email82244417f9"]`).Attr("value")
+	assert.Equal(t, "foo@example.com", email)
+	newsletterService.status = http.StatusOK
+	status, _ = post("/subscriptions/resend", "192.0.2.1", url.Values{"email82244417f9": {email}, "token": {formToken()}})
+	assert.Equal(t, http.StatusOK, status)
+	require.Len(t, newsletterService.requests, 3)
+	assert.Nil(t, newsletterService.requests[1]["resend"])
+	assert.Equal(t, true, newsletterService.requests[2]["resend"])
+
+	// the attempts are limited per email, then per IP address
+	status, doc = subscribe("192.0.2.2", "Foo@example.com", formToken())
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, tooManyAttemptsMessage, message(doc))
+
+	// an unexpected response of the newsletter service is rendered too
+	newsletterService.status = http.StatusServiceUnavailable
+	status, doc = subscribe("192.0.2.2", "baz@example.com", formToken())
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, errOops, message(doc))
+	newsletterService.status = http.StatusUnprocessableEntity
+	newsletterService.body = `{"message": "Disposable email addresses are not allowed."}`
+	status, doc = subscribe("192.0.2.2", "qux@example.com", formToken())
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, "Disposable email addresses are not allowed.", message(doc))
+
+	status, _ = subscribe("192.0.2.1", "quux@example.com", formToken())
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	// an expired confirmation link renders the resend form
+	newsletterService.status = http.StatusGone
+	rr = httptest.NewRecorder()
+	ns.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/subscriptions/confirm?token=expired", nil))
+	assert.Equal(t, http.StatusGone, rr.Code)
+	doc, err = goquery.NewDocumentFromReader(rr.Body)
+	require.NoError(t, err)
+	assert.Equal(t, expiredMessage, message(doc))
+	assert.Equal(t, 1, doc.Find(`form[action="/subscriptions/resend"]`).Length())
+}
+
+func TestSubscribeHandlerWithNewsletterService(t *testing.T) {
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Newsletter.HMAC.Secret = "secret"
+	ns, err := NewServer(zerolog.Nop(), config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ns.SearchService.CloseIndex()
+	})
+	newsletterService := &fakeNewsletterService{status: http.StatusUnauthorized}
+	ns.NewsletterService = newsletterService
+
+	token, err := newFormToken(config.Newsletter.HMAC.Secret, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	post := func(path string) *httptest.ResponseRecorder {
+		form := url.Values{"email82244417f9": {"foo@example.com"}, "token": {token}}
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		ns.router.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// the newsletter service cannot send a new confirmation email, the resend form is not shown
+	rr := post("/subscriptions")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	doc, err := goquery.NewDocumentFromReader(rr.Body)
+	require.NoError(t, err)
+	assert.Equal(t, pendingMessage, strings.TrimSpace(doc.Find(".alert").Text()))
+	assert.Equal(t, 0, doc.Find(`form[action="/subscriptions/resend"]`).Length())
+
+	post("/subscriptions/resend")
+	assert.Len(t, newsletterService.requests, 1)
+}
+
+func TestFormTokens(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokens := newFormTokens("secret", 2*time.Second, time.Hour)
+
+	token, err := newFormToken("secret", now)
+	require.NoError(t, err)
+	assert.Equal(t, errFormTooFast, tokens.verify(token, now.Add(time.Second)))
+	assert.Equal(t, errFormExpired, tokens.verify(token, now.Add(time.Hour)))
+	assert.NoError(t, tokens.verify(token, now.Add(time.Minute)))
+	assert.Equal(t, errFormReplayed, tokens.verify(token, now.Add(2*time.Minute)))
+
+	forged, err := newFormToken("other", now)
+	require.NoError(t, err)
+	assert.Equal(t, errInvalidFormToken, tokens.verify(forged, now.Add(time.Minute)))
+	assert.Equal(t, errInvalidFormToken, tokens.verify("invalid", now.Add(time.Minute)))
+}
+
+func TestFormTokensWithoutMaxAge(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokens := newFormTokens("secret", 0, -1)
+
+	token, err := newFormToken("secret", now)
+	require.NoError(t, err)
+	assert.NoError(t, tokens.verify(token, now.Add(time.Minute)))
+	assert.Equal(t, errFormReplayed, tokens.verify(token, now.Add(time.Hour)))
+
+	// the nonce is forgotten once the default maximum age has passed
+	other, err := newFormToken("secret", now)
+	require.NoError(t, err)
+	assert.NoError(t, tokens.verify(other, now.Add(time.Minute+blog.DefaultMaxFormAge)))
+	assert.Len(t, tokens.nonces, 1)
+}
+
+func TestSubscriptionDefaults(t *testing.T) {
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Newsletter.Subscription.EmailLimit = -1
+	ns, err := NewServer(zerolog.Nop(), config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ns.SearchService.CloseIndex()
+	})
+
+	assert.Equal(t, blog.DefaultSubscriptionIPLimit, ns.ipLimiter.limit)
+	assert.Equal(t, blog.DefaultSubscriptionWindow, ns.ipLimiter.window)
+	assert.Equal(t, -1, ns.emailLimiter.limit)
+	assert.Equal(t, blog.DefaultMinFormAge, ns.formTokens.minAge)
+	assert.Equal(t, blog.DefaultMaxFormAge, ns.formTokens.maxAge)
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2, time.Hour)
+
+	assert.True(t, limiter.allow("foo", now))
+	assert.True(t, limiter.allow("foo", now.Add(time.Minute)))
+	assert.False(t, limiter.allow("foo", now.Add(2*time.Minute)))
+	assert.True(t, limiter.allow("bar", now.Add(2*time.Minute)))
+	assert.True(t, limiter.allow("foo", now.Add(time.Hour)))
+	assert.False(t, limiter.allow("foo", now.Add(time.Hour)))
+
+	assert.True(t, newRateLimiter(0, time.Hour).allow("foo", now))
+
+	// the keys without recent attempts are removed every sweepInterval attempts
+	for i := 0; i < sweepInterval; i++ {
+		limiter.allow(strconv.Itoa(i), now.Add(2*time.Hour))
+	}
+	assert.NotContains(t, limiter.attempts, "foo")
+	assert.NotContains(t, limiter.attempts, "bar")
+	assert.Len(t, limiter.attempts, sweepInterval)
+}
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	require.NoError(t, err)
+	s := &Server{trustedProxies: trustedProxies}
+	clientIP := func(remoteAddr, forwardedFor, realIP string) string {
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", nil)
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if realIP != "" {
+			r.Header.Set("X-Real-Ip", realIP)
+		}
+		return s.clientIP(r)
+	}
+
+	// the headers sent by a client are ignored
+	assert.Equal(t, "198.51.100.1", clientIP("198.51.100.1:1234", "203.0.113.1", "203.0.113.2"))
+	// the address of the client is the last one forwarded which is not a trusted proxy
+	assert.Equal(t, "203.0.113.1", clientIP("10.0.0.1:1234", "198.51.100.1, 203.0.113.1, 10.0.0.2", ""))
+	assert.Equal(t, "203.0.113.2", clientIP("192.0.2.1:1234", "", "203.0.113.2"))
+	assert.Equal(t, "192.0.2.1", clientIP("192.0.2.1:1234", "", ""))
+
+	_, err = parseTrustedProxies([]string{"proxy"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	openAPIPath    = "/openapi.json"
+	openAPIVersion = "3.0.3"
+	apiVersion     = "1.0.0"
+)
+
+var (
+	// muxVariable matches the variables of a mux path, e.g. {path:.+}
+	muxVariable = regexp.MustCompile(`{([^:}]+)(:[^}]*)?}`)
+
+	timeType = reflect.TypeOf(time.Time{})
+	htmlType = reflect.TypeOf(template.HTML(""))
+)
+
+func (s *Server) openAPIHandler(config *blog.Config) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return writeJSON(w, http.StatusOK, s.openAPI(config))
+	}
+}
+
+// openAPI generates the OpenAPI document of the JSON API from the route table
+func (s *Server) openAPI(config *blog.Config) map[string]interface{} {
+	paths := make(map[string]interface{})
+	for _, route := range s.apiRoutes() {
+		parameters := make([]interface{}, 0, len(route.parameters))
+		for _, p := range route.parameters {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.required,
+				"schema": map[string]interface{}{
+					"type": p.typ,
+				},
+			})
+		}
+
+		paths[muxVariable.ReplaceAllString(apiPrefix+route.path, "{$1}")] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":    route.summary,
+				"parameters": parameters,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": schemaOf(reflect.TypeOf(route.response)),
+							},
+						},
+					},
+					"default": map[string]interface{}{
+						"description": "Error",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": schemaOf(reflect.TypeOf(Error{})),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       config.Site.Title,
+			"description": config.Site.Params.Description,
+			"version":     apiVersion,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{
+				"url": s.URL(),
+			},
+		},
+		"paths": paths,
+	}
+}
+
+// schemaOf returns the JSON schema of t, following the JSON field tags
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == htmlType:
+		return map[string]interface{}{"type": "string", "format": "html"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		addProperties(properties, t)
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// addProperties adds the exported fields of the struct t, the fields of the embedded structs are promoted
+func addProperties(properties map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addProperties(properties, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaOf(field.Type)
+	}
+}
@@ -5,5 +5,5 @@ import (
 )
 
 func (s *Server) photosHandler(w http.ResponseWriter, r *http.Request) error {
-	return s.Renderer.RenderPhotos(w)
+	return s.content().Renderer.RenderPhotos(w)
 }
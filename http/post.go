@@ -1,41 +1,47 @@
 package http
 
 import (
+	"errors"
+	"io/fs"
 	"net/http"
-	"path"
 	"strings"
 
 	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/blog/markdown"
 )
 
-func (s *Server) postHandler(postsDir string) appHandler {
+func (s *Server) postHandler() appHandler {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		uriPath := r.URL.Path
 		if hasSuffix(uriPath, []string{"jpg", "jpeg", "png", "gif"}) {
-			http.ServeFile(w, r, path.Join(postsDir, uriPath))
+			fsys := s.ContentSource.FS()
+			if _, err := fs.Stat(fsys, strings.TrimPrefix(uriPath, "/")); errors.Is(err, fs.ErrNotExist) {
+				if redirected, err := s.redirect(w, r, uriPath); redirected || err != nil {
+					return err
+				}
+			}
+			http.FileServer(http.FS(fsys)).ServeHTTP(w, r)
 		} else {
 			if !strings.HasSuffix(uriPath, ".md") {
 				uriPath += ".md"
 			}
-			currentPost := s.PostService.GetPostByURI(uriPath)
+			content := s.content()
+			currentPost := content.PostService.GetPostByURI(uriPath)
 			if currentPost == nil {
+				if redirected, err := s.redirect(w, r, uriPath); redirected || err != nil {
+					return err
+				}
 				return &Error{
 					Message: "post not found",
 					Status:  http.StatusNotFound,
 				}
 			}
 
-			relatedPosts := s.PostService.GetRelatedPosts(currentPost)
+			relatedPosts := content.PostService.GetRelatedPosts(currentPost)
+			previousPost, nextPost := content.PostService.GetPreviousAndNextPost(currentPost)
 
-			previousPost, nextPost := s.PostService.GetPreviousAndNextPost(currentPost)
-			if previousPost != nil {
-				currentPost.HasPrev = true
-			}
-			if nextPost != nil {
-				currentPost.HasNext = true
-			}
-
-			if err := s.Renderer.RenderPost(w, currentPost, relatedPosts, previousPost, nextPost); err != nil {
+			if err := content.Renderer.RenderPost(w, currentPost, relatedPosts, previousPost, nextPost); err != nil {
 				return err
 			}
 		}
@@ -44,6 +50,25 @@ func (s *Server) postHandler(postsDir string) appHandler {
 	}
 }
 
+// redirect redirects permanently to the new URI of a renamed post or asset, it reports whether there is one
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, uri string) (bool, error) {
+	if s.RedirectService == nil {
+		return false, nil
+	}
+
+	to, err := s.RedirectService.GetRedirect(uri)
+	if err != nil || to == "" {
+		return false, err
+	}
+
+	// the links to the posts do not always have the extension
+	if !strings.HasSuffix(r.URL.Path, markdown.Extension) {
+		to = strings.TrimSuffix(to, markdown.Extension)
+	}
+	http.Redirect(w, r, to, http.StatusMovedPermanently)
+	return true, nil
+}
+
 func hasSuffix(path string, suffixes []string) bool {
 	for _, suffix := range suffixes {
 		if strings.HasSuffix(path, suffix) {
@@ -54,12 +79,14 @@ func hasSuffix(path string, suffixes []string) bool {
 }
 
 func (s *Server) postsByDateHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
 	vars := mux.Vars(r)
 	year := vars["year"]
 	month := vars["month"]
 	day := vars["day"]
 
-	if err := s.Renderer.RenderPosts(w, r, s.PostService.GetPostsByDate(year, month, day)); err != nil {
+	if err := content.Renderer.RenderPosts(w, r, content.PostService.GetPostsByDate(year, month, day)); err != nil {
 		return err
 	}
 
@@ -67,11 +94,13 @@ func (s *Server) postsByDateHandler(w http.ResponseWriter, r *http.Request) erro
 }
 
 func (s *Server) postsByMonthHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
 	vars := mux.Vars(r)
 	year := vars["year"]
 	month := vars["month"]
 
-	if err := s.Renderer.RenderPosts(w, r, s.PostService.GetPostsByMonth()[year][month]); err != nil {
+	if err := content.Renderer.RenderPosts(w, r, content.PostService.GetPostsByMonth()[year][month]); err != nil {
 		return err
 	}
 
@@ -79,10 +108,12 @@ func (s *Server) postsByMonthHandler(w http.ResponseWriter, r *http.Request) err
 }
 
 func (s *Server) postsByYearHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
 	vars := mux.Vars(r)
 	year := vars["year"]
 
-	if err := s.Renderer.RenderPosts(w, r, s.PostService.GetPostsByYear(year)); err != nil {
+	if err := content.Renderer.RenderPosts(w, r, content.PostService.GetPostsByYear(year)); err != nil {
 		return err
 	}
 
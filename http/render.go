@@ -2,8 +2,10 @@ package http
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/email"
 	"github.com/quantonganh/blog/pkg/hash"
 	"github.com/quantonganh/blog/ui/html"
 )
@@ -60,6 +63,21 @@ func (r *render) RenderTags(w http.ResponseWriter) error {
 	return nil
 }
 
+// RenderSeries renders the list of series with their parts
+func (r *render) RenderSeries(w http.ResponseWriter) error {
+	tmpl := html.Parse(nil, "series.html")
+	data := map[string]interface{}{
+		"Title":      "Series",
+		"categories": r.postService.GetAllCategories(),
+		"series":     r.postService.GetAllSeries(),
+	}
+	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+		return errors.Errorf("failed to execute template: %v", err)
+	}
+
+	return nil
+}
+
 // RenderArchives renders archives page
 func (r *render) RenderArchives(w http.ResponseWriter) error {
 	tmpl := html.Parse(template.FuncMap{
@@ -80,18 +98,9 @@ func (r *render) RenderArchives(w http.ResponseWriter) error {
 
 // RenderPosts renders blogs posts
 func (r *render) RenderPosts(w http.ResponseWriter, req *http.Request, posts []*blog.Post) error {
-	var (
-		postsPerPage int
-		err          error
-	)
-	postsPerPageEnv, exists := os.LookupEnv("POSTS_PER_PAGE")
-	if !exists {
-		postsPerPage = defaultPostsPerPage
-	} else {
-		postsPerPage, err = strconv.Atoi(postsPerPageEnv)
-		if err != nil {
-			return errors.Errorf("failed to convert %s to int: %v", postsPerPageEnv, err)
-		}
+	postsPerPage, err := getPostsPerPage()
+	if err != nil {
+		return err
 	}
 
 	nums := len(posts)
@@ -119,16 +128,73 @@ func (r *render) RenderPosts(w http.ResponseWriter, req *http.Request, posts []*
 	return nil
 }
 
+// RenderSearchResults renders a page of search results with the snippets and the facets
+func (r *render) RenderSearchResults(w http.ResponseWriter, req *http.Request, searchRequest *blog.SearchRequest, result *blog.SearchResult) error {
+	paginator := pagination.NewPaginator(req, searchRequest.Size, int64(result.Total))
+
+	tmpl := html.Parse(template.FuncMap{
+		"toISODate": blog.ToISODate,
+	}, "search.html")
+	data := map[string]interface{}{
+		"Site":       r.config.Site,
+		"Title":      "Search: " + searchRequest.Query,
+		"noindex":    true,
+		"categories": r.postService.GetAllCategories(),
+		"query":      searchRequest.Query,
+		"sort":       searchRequest.Sort,
+		"result":     result,
+		"paginator":  paginator,
+	}
+	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+		return errors.Errorf("failed to execute template: %v", err)
+	}
+
+	return nil
+}
+
+// getPostsPerPage returns the number of posts per page, configurable via POSTS_PER_PAGE
+func getPostsPerPage() (int, error) {
+	postsPerPageEnv, exists := os.LookupEnv("POSTS_PER_PAGE")
+	if !exists {
+		return defaultPostsPerPage, nil
+	}
+
+	postsPerPage, err := strconv.Atoi(postsPerPageEnv)
+	if err != nil {
+		return 0, errors.Errorf("failed to convert %s to int: %v", postsPerPageEnv, err)
+	}
+
+	return postsPerPage, nil
+}
+
 // RenderPost renders a single blog post
 func (r *render) RenderPost(w http.ResponseWriter, currentPost *blog.Post, relatedPosts []*blog.Post, previousPost, nextPost *blog.Post) error {
 	tmpl := html.Parse(template.FuncMap{
-		"toISODate": blog.ToISODate,
+		"toISODate":      blog.ToISODate,
+		"toLanguageName": toLanguageName,
 	}, "post.html")
+	// an unlisted post is not one of the parts of its series, the navigation is not shown
+	var (
+		series []*blog.Post
+		part   int
+	)
+	parts := r.postService.GetPostsBySeries(currentPost.Series)
+	for i, p := range parts {
+		if p.URI == currentPost.URI {
+			series, part = parts, i+1
+		}
+	}
+
 	data := map[string]interface{}{
 		"categories":   r.postService.GetAllCategories(),
 		"Title":        currentPost.Title,
 		"Description":  currentPost.Description,
+		"noindex":      currentPost.Unlisted,
+		"lang":         currentPost.Lang,
 		"currentPost":  currentPost,
+		"translations": r.postService.GetTranslations(currentPost),
+		"series":       series,
+		"part":         part,
 		"relatedPosts": relatedPosts,
 		"previousPost": previousPost,
 		"nextPost":     nextPost,
@@ -156,27 +222,91 @@ func (r *render) RenderResponseMessage(w http.ResponseWriter, contextualClass, m
 	return nil
 }
 
-// RenderNewsletter renders newsletter
-func (r *render) RenderNewsletter(latestPosts []*blog.Post, serverURL, email string) (*bytes.Buffer, error) {
-	funcMap := template.FuncMap{
-		"toISODate": blog.ToISODate,
+// RenderResendConfirmation renders HTTP response message with a form to resend the confirmation email to email
+func (r *render) RenderResendConfirmation(w http.ResponseWriter, contextualClass, message, email string) error {
+	tmpl := html.Parse(nil, "subscribe.html")
+	data := map[string]interface{}{
+		"categories":      r.postService.GetAllCategories(),
+		"contextualClass": contextualClass,
+		"message":         message,
+		"resend":          true,
+		"email":           email,
 	}
-	tmpl := template.Must(template.New("").Funcs(funcMap).ParseFS(html.FS, "newsletter.html"))
-	hash, err := hash.ComputeHmac256(email, r.config.Newsletter.HMAC.Secret)
+
+	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+		return errors.Errorf("failed to execute template: %v", err)
+	}
+
+	return nil
+}
+
+// RenderNewsletter renders the newsletter sent to email as a MIME message: the HTML with its CSS inlined,
+// a plain text alternative and the headers of the one-click unsubscribe. The URLs are resolved against
+// the base URL of the site, or serverURL if it is not set.
+func (r *render) RenderNewsletter(latestPosts []*blog.Post, serverURL, to string) (*bytes.Buffer, error) {
+	baseURL := r.config.Site.BaseURL
+	if baseURL == "" {
+		baseURL = serverURL
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse URL %s", baseURL)
+	}
+
+	hash, err := hash.ComputeHmac256(to, r.config.Newsletter.HMAC.Secret)
 	if err != nil {
 		return nil, err
 	}
+	unsubscribeURL := base.ResolveReference(&url.URL{
+		Path: "/unsubscribe",
+		RawQuery: url.Values{
+			"email": {to},
+			"hash":  {hash},
+		}.Encode(),
+	}).String()
+
+	funcMap := template.FuncMap{
+		"toISODate": blog.ToISODate,
+	}
+	tmpl := template.Must(template.New("").Funcs(funcMap).ParseFS(html.FS, "newsletter.html"))
 	buf := new(bytes.Buffer)
 	data := map[string]interface{}{
-		"categories": r.postService.GetAllCategories(),
-		"posts":      latestPosts,
-		"pageURL":    serverURL,
-		"email":      email,
-		"hash":       hash,
+		"posts":          latestPosts,
+		"unsubscribeURL": unsubscribeURL,
 	}
 	if err := tmpl.ExecuteTemplate(buf, "newsletter", data); err != nil {
 		return nil, errors.Errorf("failed to execute template newsletter: %v", err)
 	}
 
-	return buf, nil
+	htmlBody, err := email.InlineCSS(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	htmlBody, err = absoluteURLs(htmlBody, base)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := newsletterText(latestPosts, base, unsubscribeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &email.Message{
+		From:    r.config.Newsletter.From,
+		To:      to,
+		Subject: newsletterSubject(r.config),
+		Header: map[string]string{
+			"List-Unsubscribe":      fmt.Sprintf("<%s>", unsubscribeURL),
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+		HTML: htmlBody,
+		Text: text,
+	}
+	message, err := msg.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(message), nil
 }
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+// PublishScheduledPosts announces the posts published since the last announcement, then checks the scheduled posts
+// every interval and publishes the ones whose publish date has passed: they are indexed, listed and announced
+// to the "added-posts" queue
+func (s *Server) PublishScheduledPosts(ctx context.Context, interval time.Duration) error {
+	if err := s.publishDuePosts(time.Now()); err != nil {
+		s.logger.Error().Err(err).Msg("failed to publish scheduled posts")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if err := s.publishDuePosts(now); err != nil {
+				s.logger.Error().Err(err).Msg("failed to publish scheduled posts")
+			}
+		}
+	}
+}
+
+// publishDuePosts lists the scheduled posts whose publish date has passed, then announces the posts published since
+// the last announcement: the ones listed by another reload or while the blog was stopped are announced too
+func (s *Server) publishDuePosts(now time.Time) error {
+	var duePosts []*blog.Post
+	for _, p := range s.content().PostService.GetScheduledPosts() {
+		if p.IsPublished(now) {
+			duePosts = append(duePosts, p)
+		}
+	}
+	if len(duePosts) > 0 {
+		if err := s.reload(nil, nil, duePosts); err != nil {
+			return err
+		}
+
+		for _, p := range duePosts {
+			s.logger.Info().Str("uri", p.URI).Msg("scheduled post published")
+		}
+	}
+
+	return s.announcePublishedPosts(now)
+}
+
+// announcePublishedPosts announces the listed posts whose publish date is in (last announcement, now], oldest first,
+// then records now as the time up to which the posts have been announced
+func (s *Server) announcePublishedPosts(now time.Time) error {
+	s.announceMu.Lock()
+	defer s.announceMu.Unlock()
+
+	last, err := s.lastAnnounced()
+	if err != nil {
+		return err
+	}
+
+	var posts []*blog.Post
+	for _, p := range s.content().PostService.GetAllPosts() {
+		if p.PublishDate.After(last) && !p.PublishDate.After(now) {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].PublishDate.Before(posts[j].PublishDate.Time)
+	})
+
+	for _, p := range posts {
+		if err := s.publishAddedPosts([]*blog.Post{p}); err != nil {
+			// the posts published before the failed one are not announced again
+			if until := p.PublishDate.Add(-time.Nanosecond); until.After(last) {
+				if err := s.setLastAnnounced(until); err != nil {
+					s.logger.Error().Err(err).Msg("failed to record the last announcement")
+				}
+			}
+			return err
+		}
+	}
+
+	return s.setLastAnnounced(now)
+}
+
+// lastAnnounced returns the time up to which the posts have been announced,
+// the posts published before the server is created are not announced if none has been recorded
+func (s *Server) lastAnnounced() (time.Time, error) {
+	if s.AnnouncementService == nil {
+		return s.announcedUntil, nil
+	}
+
+	last, err := s.AnnouncementService.LastAnnounced()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last.IsZero() {
+		return s.announcedUntil, nil
+	}
+
+	return last, nil
+}
+
+func (s *Server) setLastAnnounced(t time.Time) error {
+	s.announcedUntil = t
+	if s.AnnouncementService == nil {
+		return nil
+	}
+
+	return s.AnnouncementService.SetLastAnnounced(t)
+}
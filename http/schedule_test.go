@@ -0,0 +1,190 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+type fakeQueueService struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+	// err is returned by Publish if it is set
+	err error
+}
+
+func (q *fakeQueueService) Publish(topic string, message []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.err != nil {
+		return q.err
+	}
+	q.messages[topic] = append(q.messages[topic], message)
+	return nil
+}
+
+type fakeAnnouncementService struct {
+	last time.Time
+}
+
+func (s *fakeAnnouncementService) LastAnnounced() (time.Time, error) {
+	return s.last, nil
+}
+
+func (s *fakeAnnouncementService) SetLastAnnounced(t time.Time) error {
+	s.last = t
+	return nil
+}
+
+func TestScheduledAndUnlistedPosts(t *testing.T) {
+	t.Parallel()
+
+	// the publish date is truncated to the second, it must still be in the future once the server is created
+	publishDate := time.Now().Add(2 * time.Second)
+	var posts []*blog.Post
+	for _, content := range []string{
+		`---
+title: Listed
+date: 2021-01-01
+---
+Listed.`,
+		`---
+title: Unlisted
+date: 2021-01-02
+unlisted: true
+---
+Unlisted.`,
+		fmt.Sprintf(`---
+title: Scheduled
+date: 2021-01-03
+publishDate: %s
+---
+Scheduled.`, publishDate.Format("Mon Jan 2 15:04:05 -07 2006")),
+		`---
+title: Draft
+date: 2021-01-04
+draft: true
+---
+Draft.`,
+	} {
+		p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(content))
+		require.NoError(t, err)
+		posts = append(posts, p)
+	}
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+	queue := &fakeQueueService{
+		messages: make(map[string][][]byte),
+	}
+	ss.QueueService = queue
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		ss.router.ServeHTTP(rr, request)
+		return rr
+	}
+
+	home := get("/").Body.String()
+	assert.Contains(t, home, "/2021/01/01/listed.md")
+	assert.NotContains(t, home, "/2021/01/02/unlisted.md")
+	assert.NotContains(t, home, "/2021/01/03/scheduled.md")
+	assert.NotContains(t, home, "/2021/01/04/draft.md")
+
+	assert.Equal(t, http.StatusOK, get("/2021/01/02/unlisted").Code)
+	assert.Equal(t, http.StatusNotFound, get("/2021/01/03/scheduled").Code)
+	assert.Equal(t, http.StatusNotFound, get("/2021/01/04/draft").Code)
+	assert.NotContains(t, get("/sitemap.xml").Body.String(), "unlisted")
+
+	require.Eventually(t, func() bool {
+		assert.NoError(t, ss.publishDuePosts(time.Now()))
+		return len(ss.content().PostService.GetScheduledPosts()) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, get("/2021/01/03/scheduled").Code)
+	assert.Contains(t, get("/").Body.String(), "/2021/01/03/scheduled.md")
+	assert.Empty(t, ss.content().PostService.GetScheduledPosts())
+	assert.Len(t, queue.messages["added-posts"], 1)
+}
+
+func TestAnnounceScheduledPostListedByReload(t *testing.T) {
+	t.Parallel()
+
+	publishDate := time.Now().Add(2 * time.Second)
+	p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(fmt.Sprintf(`---
+title: Scheduled
+date: 2021-01-03
+publishDate: %s
+---
+Scheduled.`, publishDate.Format("Mon Jan 2 15:04:05 -07 2006"))))
+	require.NoError(t, err)
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, []*blog.Post{p})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+	queue := &fakeQueueService{
+		messages: make(map[string][][]byte),
+	}
+	ss.QueueService = queue
+	announcements := &fakeAnnouncementService{}
+	ss.AnnouncementService = announcements
+	require.Len(t, ss.content().PostService.GetScheduledPosts(), 1)
+
+	// an unrelated reload lands after the publish date but before the tick
+	require.Eventually(t, func() bool {
+		return time.Now().After(p.PublishDate.Time)
+	}, 5*time.Second, 100*time.Millisecond)
+	require.NoError(t, ss.reload(nil, nil, nil))
+	require.Empty(t, ss.content().PostService.GetScheduledPosts())
+
+	search := func() int {
+		rr := httptest.NewRecorder()
+		ss.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=scheduled", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp searchResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp.Total
+	}
+	assert.Equal(t, 1, search())
+
+	// the tick finds no scheduled post, the post is announced once all the same
+	now := time.Now()
+	require.NoError(t, ss.publishDuePosts(now))
+	assert.Len(t, queue.messages["added-posts"], 1)
+	assert.Equal(t, now, announcements.last)
+	require.NoError(t, ss.publishDuePosts(time.Now()))
+	assert.Len(t, queue.messages["added-posts"], 1)
+
+	// a restart after the publish date announces the post if it has not been announced yet
+	announcements.last = p.PublishDate.Add(-time.Minute)
+	require.NoError(t, ss.publishDuePosts(time.Now()))
+	assert.Len(t, queue.messages["added-posts"], 2)
+}
@@ -2,6 +2,11 @@ package http
 
 import (
 	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
 )
 
 func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) error {
@@ -9,10 +14,43 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) error {
 		return NewError(err, http.StatusBadRequest, "Bad request: invalid search query")
 	}
 
-	searchPosts, err := s.SearchService.Search(r.FormValue("q"))
+	size, err := getPostsPerPage()
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(r.FormValue("p"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	searchRequest := &blog.SearchRequest{
+		Query: r.FormValue("q"),
+		Sort:  r.FormValue("sort"),
+		Page:  page,
+		Size:  size,
+	}
+	result, err := s.SearchService.Search(searchRequest)
 	if err != nil {
+		if errors.Cause(err) == blog.ErrInvalidQuery {
+			return NewError(err, http.StatusBadRequest, "Bad request: invalid search query")
+		}
 		return err
 	}
 
-	return s.Renderer.RenderPosts(w, r, searchPosts)
+	content := s.content()
+	resolveHits(content.PostService, result)
+
+	return content.Renderer.RenderSearchResults(w, r, searchRequest, result)
+}
+
+// resolveHits sets the post of each hit, the hits of the posts which are no longer published are dropped.
+// The total is left as the index reports it, since the posts which are no longer published are deleted from the index on reload
+func resolveHits(postService blog.PostService, result *blog.SearchResult) {
+	hits := result.Hits[:0]
+	for _, hit := range result.Hits {
+		if hit.Post = postService.GetPostByURI(hit.URI); hit.Post != nil {
+			hits = append(hits, hit)
+		}
+	}
+	result.Hits = hits
 }
@@ -0,0 +1,18 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) seriesHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.content().Renderer.RenderSeries(w)
+}
+
+func (s *Server) postsBySeriesHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	series := mux.Vars(r)["seriesName"]
+	return content.Renderer.RenderPosts(w, r, content.PostService.GetPostsBySeries(series))
+}
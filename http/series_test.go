@@ -0,0 +1,103 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+func TestSeries(t *testing.T) {
+	t.Parallel()
+
+	var posts []*blog.Post
+	for _, content := range []string{
+		`---
+title: Deploy
+date: 2021-01-04
+series: Kubernetes
+seriesOrder: 3
+---
+Deploy.`,
+		`---
+title: Unrelated
+date: 2021-01-03
+---
+Unrelated.`,
+		`---
+title: Install
+date: 2021-01-02
+series: Kubernetes
+seriesOrder: 1
+---
+Install.`,
+		`---
+title: Configure
+date: 2021-01-01
+series: Kubernetes
+seriesOrder: 2
+---
+Configure.`,
+		`---
+title: Extras
+date: 2021-01-05
+series: Kubernetes
+unlisted: true
+---
+Extras.`,
+	} {
+		p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(content))
+		require.NoError(t, err)
+		posts = append(posts, p)
+	}
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+
+	parts := ss.content().PostService.GetPostsBySeries("Kubernetes")
+	require.Equal(t, 3, len(parts))
+	assert.Equal(t, []string{"Install", "Configure", "Deploy"}, []string{parts[0].Title, parts[1].Title, parts[2].Title})
+
+	previousPost, nextPost := ss.content().PostService.GetPreviousAndNextPost(parts[1])
+	assert.Equal(t, "Install", previousPost.Title)
+	assert.Equal(t, "Deploy", nextPost.Title)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		ss.router.ServeHTTP(rr, request)
+		return rr
+	}
+
+	post := get("/2021/01/01/configure").Body.String()
+	assert.Contains(t, post, "Part 2 of 3 in the series")
+	assert.Contains(t, post, `href="/2021/01/02/install.md"`)
+	assert.Contains(t, post, `href="/2021/01/04/deploy.md"`)
+	assert.NotContains(t, post, "/2021/01/03/unrelated.md")
+	assert.NotContains(t, post, "/2021/01/05/extras.md")
+
+	extras := get("/2021/01/05/extras")
+	assert.Equal(t, http.StatusOK, extras.Code)
+	assert.NotContains(t, extras.Body.String(), "in the series")
+
+	assert.Contains(t, get("/series").Body.String(), `href="/series/Kubernetes"`)
+	list := get("/series/Kubernetes").Body.String()
+	assert.NotContains(t, list, "/2021/01/03/unrelated.md")
+	assert.Less(t, strings.Index(list, "/2021/01/02/install.md"), strings.Index(list, "/2021/01/01/configure.md"))
+}
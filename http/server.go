@@ -12,6 +12,8 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	sentryhttp "github.com/getsentry/sentry-go/http"
@@ -23,6 +25,7 @@ import (
 	"github.com/quantonganh/blog"
 	"github.com/quantonganh/blog/client"
 	"github.com/quantonganh/blog/markdown"
+	"github.com/quantonganh/blog/source"
 	"github.com/quantonganh/blog/ui"
 	"github.com/quantonganh/httperror"
 )
@@ -38,37 +41,91 @@ type Server struct {
 	server *http.Server
 	router *mux.Router
 
+	liveReload  *liveReload
+	config      *blog.Config
+	converter   markdown.Converter
+	recommender *markdown.Recommender
+
+	// deliveryAccepted wakes the worker processing the webhook deliveries up
+	deliveryAccepted chan struct{}
+
+	// formTokens, ipLimiter and emailLimiter protect the subscription form against bots
+	formTokens   *formTokens
+	ipLimiter    *rateLimiter
+	emailLimiter *rateLimiter
+	// trustedProxies are the proxies whose forwarded headers are trusted to find the IP address of a client
+	trustedProxies []*net.IPNet
+
+	// current is the version of the content served by the handlers, reloadMu serializes the reloads replacing it
+	current  atomic.Pointer[snapshot]
+	reloadMu sync.Mutex
+
+	// announceMu serializes the announcements, announcedUntil is the time up to which the posts have been announced
+	announceMu     sync.Mutex
+	announcedUntil time.Time
+
 	Addr   string
 	Domain string
 
-	PostService       blog.PostService
+	// ContentSource provides the files of the posts, it is the posts directory unless it is replaced
+	ContentSource     blog.ContentSource
 	SearchService     blog.SearchService
-	Renderer          blog.Renderer
 	NewsletterService blog.NewsletterService
 	QueueService      blog.QueueService
 	EventService      blog.EventService
 	StatService       blog.StatService
+	DeliveryService   blog.DeliveryService
+	RedirectService   blog.RedirectService
+	SMTPService       blog.SMTPService
+	SubscriberService blog.SubscriberService
+	DigestService     blog.DigestService
+	// AnnouncementService persists the time up to which the posts have been announced, it is kept in memory if not set
+	AnnouncementService blog.AnnouncementService
 }
 
 // NewServer create new HTTP server
 func NewServer(logger zerolog.Logger, config *blog.Config, posts []*blog.Post) (*Server, error) {
-	postService := markdown.NewPostService(posts)
+	converter, err := markdown.NewConverter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := config.Newsletter.Subscription.WithDefaults()
+	trustedProxies, err := parseTrustedProxies(subscription.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	recommender := markdown.NewRecommender()
+	content := newSnapshot(config, posts, recommender)
 	indexPath := path.Join(path.Dir(config.Posts.Dir), path.Base(config.Posts.Dir)+".bleve")
-	searchService, err := markdown.NewSearchService(indexPath, posts)
+	searchService, err := markdown.NewSearchService(indexPath, content.PostService.GetAllPosts())
 	if err != nil {
 		return nil, err
 	}
 
 	s := &Server{
-		logger:            logger,
-		server:            &http.Server{},
-		router:            mux.NewRouter().StrictSlash(true),
-		PostService:       postService,
-		SearchService:     searchService,
-		Renderer:          NewRender(config, postService),
-		NewsletterService: client.NewNewsletter(config.Newsletter.BaseURL),
+		logger:           logger,
+		server:           &http.Server{},
+		router:           mux.NewRouter().StrictSlash(true),
+		config:           config,
+		converter:        converter,
+		recommender:      recommender,
+		deliveryAccepted: make(chan struct{}, 1),
+		announcedUntil:   time.Now(),
+		formTokens:       newFormTokens(config.Newsletter.HMAC.Secret, subscription.MinFormAge, subscription.MaxFormAge),
+		ipLimiter:        newRateLimiter(subscription.IPLimit, subscription.Window),
+		trustedProxies:   trustedProxies,
+		emailLimiter:     newRateLimiter(subscription.EmailLimit, subscription.Window),
+		ContentSource:    source.NewDir(config.Posts.Dir, config.Branch()),
+		SearchService:    searchService,
+	}
+	if config.Newsletter.BaseURL != "" {
+		s.NewsletterService = client.NewNewsletter(config.Newsletter.BaseURL)
 	}
 
+	s.current.Store(content)
+
 	s.router.Use(hlog.NewHandler(logger))
 	s.router.Use(hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
 		if !strings.HasPrefix(r.URL.Path, "/static") && !hasSuffix(r.URL.Path, []string{"ico", "jpg", "jpeg", "png", "gif"}) {
@@ -86,7 +143,7 @@ func NewServer(logger zerolog.Logger, config *blog.Config, posts []*blog.Post) (
 				Dur("duration", duration).
 				Msg("")
 
-			if config.Env != "local" {
+			if config.Env != "local" && s.EventService != nil {
 				go func() {
 					pattern := `^/\d{4}/\d{2}/\d{2}/[a-z-]+(\.md)?$`
 					regex := regexp.MustCompile(pattern)
@@ -142,32 +199,50 @@ func NewServer(logger zerolog.Logger, config *blog.Config, posts []*blog.Post) (
 	s.newRoute("/favicon.ico", faviconHandler)
 	s.newRoute("/", s.homeHandler)
 	s.router.NotFoundHandler = s.Error(s.homeHandler)
-	s.newRoute("/{year:20[0-9][0-9]}/{month:0[1-9]|1[012]}/{day:0[1-9]|[12][0-9]|3[01]}/{postName}", s.postHandler(config.Posts.Dir))
+	s.newRoute("/{year:20[0-9][0-9]}/{month:0[1-9]|1[012]}/{day:0[1-9]|[12][0-9]|3[01]}/{postName}", s.postHandler())
 	s.newRoute("/{year:20[0-9][0-9]}/{month:0[1-9]|1[012]}/{day:0[1-9]|[12][0-9]|3[01]}", s.postsByDateHandler)
 	s.newRoute("/{year:20[0-9][0-9]}/{month:0[1-9]|1[012]}", s.postsByMonthHandler)
 	s.newRoute("/{year:20[0-9][0-9]}", s.postsByYearHandler)
-	s.router.PathPrefix("/about").HandlerFunc(s.Error(s.postHandler(config.Posts.Dir)))
-	s.router.PathPrefix("/resume").HandlerFunc(s.Error(s.postHandler(config.Posts.Dir)))
-	s.router.PathPrefix("/projects").HandlerFunc(s.Error(s.postHandler(config.Posts.Dir)))
-	s.router.PathPrefix("/uses").HandlerFunc(s.Error(s.postHandler(config.Posts.Dir)))
-	s.router.PathPrefix("/now").HandlerFunc(s.Error(s.postHandler(config.Posts.Dir)))
+	s.router.PathPrefix("/about").HandlerFunc(s.Error(s.postHandler()))
+	s.router.PathPrefix("/resume").HandlerFunc(s.Error(s.postHandler()))
+	s.router.PathPrefix("/projects").HandlerFunc(s.Error(s.postHandler()))
+	s.router.PathPrefix("/uses").HandlerFunc(s.Error(s.postHandler()))
+	s.router.PathPrefix("/now").HandlerFunc(s.Error(s.postHandler()))
 	s.newRoute("/photos", s.photosHandler)
 	s.newRoute("/categories/{categoryName}", s.categoryHandler)
 	s.newRoute("/tags", s.tagsHandler)
 	s.newRoute("/archives", s.archivesHandler)
 	s.newRoute("/tags/{tagName}", s.tagHandler)
+	s.newRoute("/series", s.seriesHandler)
+	s.newRoute("/series/{seriesName}", s.postsBySeriesHandler)
+	s.newRoute(langPrefix+"/", s.homeHandler)
+	s.newRoute(langPrefix+"/categories/{categoryName}", s.categoryHandler)
+	s.newRoute(langPrefix+"/tags/{tagName}", s.tagHandler)
 	s.router.PathPrefix("/static/").Handler(http.FileServer(http.FS(ui.StaticFS)))
 	s.newRoute("/search", s.searchHandler)
+	for _, route := range s.apiRoutes() {
+		s.newAPIRoute(route)
+	}
+	s.router.HandleFunc(apiPrefix+openAPIPath, s.apiError(s.openAPIHandler(config))).Methods(http.MethodGet)
 	s.newRoute("/sitemap.xml", s.sitemapHandler)
-	s.newRoute("/rss.xml", s.rssHandler)
+	for _, prefix := range []string{"", "/tags/{tagName}", "/categories/{categoryName}"} {
+		s.newRoute(prefix+"/rss.xml", s.feedHandler(config, rssFormat))
+		s.newRoute(prefix+"/atom.xml", s.feedHandler(config, atomFormat))
+		s.newRoute(prefix+"/feed.json", s.feedHandler(config, jsonFormat))
+	}
 
-	s.newRoute("/subscriptions", s.subscribeHandler).Methods(http.MethodPost)
+	s.newRoute("/subscriptions", s.requireNewsletter(s.subscribeHandler)).Methods(http.MethodPost)
 	subRouter := s.router.PathPrefix("/subscriptions").Subrouter()
-	subRouter.HandleFunc("/confirm", s.Error(s.confirmHandler))
-	s.newRoute("/unsubscribe", s.unsubscribeHandler)
+	subRouter.HandleFunc("/token", s.Error(s.requireNewsletter(s.formTokenHandler))).Methods(http.MethodGet)
+	subRouter.HandleFunc("/confirm", s.Error(s.requireNewsletter(s.confirmHandler)))
+	if s.canResendConfirmation() {
+		subRouter.HandleFunc("/resend", s.Error(s.requireNewsletter(s.resendHandler))).Methods(http.MethodPost)
+	}
+	s.newRoute("/unsubscribe", s.requireNewsletter(s.unsubscribeHandler))
 
 	if config.Env != "local" {
 		s.newRoute("/webhook", s.webhookHandler(config)).Methods(http.MethodPost)
+		s.newRoute("/webhook/deliveries", s.deliveriesHandler(config))
 		s.newRoute("/stats", s.statsHandler)
 	}
 
@@ -236,7 +311,14 @@ func faviconHandler(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) error {
-	if err := s.Renderer.RenderPosts(w, r, s.PostService.GetAllPosts()); err != nil {
+	content := s.content()
+
+	lang, err := getLang(r)
+	if err != nil {
+		return err
+	}
+
+	if err := content.Renderer.RenderPosts(w, r, blog.FilterByLang(content.PostService.GetAllPosts(), lang)); err != nil {
 		return err
 	}
 
@@ -64,8 +64,11 @@ tags:
   - test
 ---
 Test.`)
-	var err error
-	post, err = markdown.Parse(context.Background(), ".", r)
+	converter, err := markdown.NewConverter(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	post, err = markdown.Parse(context.Background(), converter, ".", r)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -90,6 +93,9 @@ func TestParseMarkdown(t *testing.T) {
 	assert.Equal(t, "Just a test", post.Description)
 	assert.Equal(t, "test", post.Tags[0])
 	assert.Equal(t, template.HTML("<p>Test.</p>\n"), post.Content)
+	assert.Equal(t, 1, post.WordCount)
+	assert.Equal(t, 1, post.ReadingTime)
+	assert.Equal(t, "en", post.Lang)
 }
 
 func TestFaviconHandler(t *testing.T) {
@@ -152,6 +158,17 @@ func TestSearchHandler(t *testing.T) {
 	})
 }
 
+func TestSearchHandlerInvalidQuery(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/search?q=%22test", nil)
+	assert.NoError(t, err)
+	s.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 func getLinkByText(t *testing.T, body *bytes.Buffer, text string) string {
 	doc, err := goquery.NewDocumentFromReader(body)
 	require.NoError(t, err)
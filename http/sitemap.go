@@ -13,6 +13,8 @@ import (
 const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
 
 func (s *Server) sitemapHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
 	sitemap := blog.Sitemap{
 		XMLNS: xmlns,
 		URLs: []blog.URL{
@@ -22,11 +24,20 @@ func (s *Server) sitemapHandler(w http.ResponseWriter, r *http.Request) error {
 		},
 	}
 
-	for _, p := range s.PostService.GetAllPosts() {
-		sitemap.URLs = append(sitemap.URLs, blog.URL{
+	for _, p := range content.PostService.GetAllPosts() {
+		entry := blog.URL{
 			Loc:     fmt.Sprintf("%s%s", s.URL(), p.URI),
 			LastMod: blog.ToISODate(p.Date),
-		})
+		}
+		// every version lists all the versions including itself
+		for _, version := range append([]*blog.Post{p}, content.PostService.GetTranslations(p)...) {
+			entry.Alternates = append(entry.Alternates, blog.AlternateLink{
+				Rel:      "alternate",
+				HrefLang: version.Lang,
+				Href:     fmt.Sprintf("%s%s", s.URL(), version.URI),
+			})
+		}
+		sitemap.URLs = append(sitemap.URLs, entry)
 	}
 
 	output, err := xml.MarshalIndent(sitemap, "  ", "    ")
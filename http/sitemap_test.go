@@ -26,4 +26,6 @@ func TestSitemapHandler(t *testing.T) {
 	assert.Equal(t, 2, len(sitemap.URLs))
 	assert.Equal(t, "http://localhost", sitemap.URLs[0].Loc)
 	assert.Equal(t, "http://localhost/2019/09/19/test.md", sitemap.URLs[1].Loc)
+	require.Equal(t, 1, len(sitemap.URLs[1].Alternates))
+	assert.Equal(t, "en", sitemap.URLs[1].Alternates[0].HrefLang)
 }
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"bytes"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+// snapshot is a version of the content: the posts with their indexes and the renderer built from them.
+// It is never modified, a reload builds a new one and swaps it atomically.
+type snapshot struct {
+	PostService blog.PostService
+	Renderer    blog.Renderer
+	// Version is the version of the content source the posts were read from, it is empty if it is unknown
+	Version string
+}
+
+func newSnapshot(config *blog.Config, posts []*blog.Post, recommender *markdown.Recommender) *snapshot {
+	postService := markdown.NewPostService(posts, recommender)
+	return &snapshot{
+		PostService: postService,
+		Renderer:    NewRender(config, postService),
+	}
+}
+
+// content returns the current version of the content,
+// a handler calls it once so that everything it reads comes from the same version
+func (s *Server) content() *snapshot {
+	return s.current.Load()
+}
+
+// RenderNewsletter renders the newsletter with the current content, so the server can be used as the renderer of the SMTP service
+func (s *Server) RenderNewsletter(latestPosts []*blog.Post, serverURL, email string) (*bytes.Buffer, error) {
+	return s.content().Renderer.RenderNewsletter(latestPosts, serverURL, email)
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+func parseTestPost(t *testing.T, day int, content string) *blog.Post {
+	p, err := markdown.Parse(context.Background(), s.converter, ".", strings.NewReader(fmt.Sprintf(`---
+title: Day%d
+date: 2021-01-%02d
+tags: [test]
+categories: [Test]
+---
+%s`, day, day, content)))
+	require.NoError(t, err)
+
+	return p
+}
+
+// TestReloadWhileServing must be run with the race detector: the handlers read the content while it is reloaded
+func TestReloadWhileServing(t *testing.T) {
+	t.Parallel()
+
+	var posts []*blog.Post
+	for day := 10; day > 0; day-- {
+		posts = append(posts, parseTestPost(t, day, "A post about testing."))
+	}
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	for _, url := range []string{
+		"/",
+		"/tags",
+		"/tags/test",
+		"/categories/Test",
+		"/archives",
+		"/2021/01/05/day5",
+		"/sitemap.xml",
+		"/rss.xml",
+		"/search?q=testing",
+		"/api/v1/posts",
+		"/api/v1/posts/2021/01/05/day5.md",
+	} {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				rr := httptest.NewRecorder()
+				request, err := http.NewRequest(http.MethodGet, url, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ss.router.ServeHTTP(rr, request)
+				if rr.Code != http.StatusOK {
+					t.Errorf("%s: unexpected status %d", url, rr.Code)
+					return
+				}
+			}
+		}(url)
+	}
+
+	for day := 11; day <= 20; day++ {
+		added := []*blog.Post{parseTestPost(t, day, "A new post about testing.")}
+		modified := []*blog.Post{parseTestPost(t, 5, fmt.Sprintf("Modified %d times.", day-10))}
+		require.NoError(t, ss.reload(added, nil, modified))
+	}
+	close(done)
+	wg.Wait()
+
+	postService := ss.content().PostService
+	require.Equal(t, 20, len(postService.GetAllPosts()))
+	for i, p := range postService.GetAllPosts() {
+		assert.Equal(t, i, p.ID)
+	}
+
+	current := postService.GetPostByURI("/2021/01/20/day20.md")
+	require.NotNil(t, current)
+	previousPost, nextPost := postService.GetPreviousAndNextPost(current)
+	assert.Equal(t, "/2021/01/19/day19.md", previousPost.URI)
+	assert.Nil(t, nextPost)
+	assert.Contains(t, string(postService.GetPostByURI("/2021/01/05/day5.md").Content), "Modified 10 times.")
+}
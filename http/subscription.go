@@ -0,0 +1,245 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/hash"
+)
+
+var (
+	errInvalidFormToken = errors.New("invalid form token")
+	errFormTooFast      = errors.New("form submitted too fast")
+	errFormExpired      = errors.New("form has expired")
+	errFormReplayed     = errors.New("form has already been submitted")
+)
+
+// newFormToken returns the token of a subscription form rendered at now: the time, a nonce and their HMAC
+func newFormToken(secret string, now time.Time) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate nonce")
+	}
+
+	payload := strconv.FormatInt(now.Unix(), 10) + "." + hex.EncodeToString(b)
+	signature, err := hash.ComputeHmac256(payload, secret)
+	if err != nil {
+		return "", err
+	}
+
+	return payload + "." + signature, nil
+}
+
+// formTokens verifies the tokens of the subscription forms, remembering the nonces of the accepted ones
+// until they expire so that a submitted form cannot be replayed
+type formTokens struct {
+	secret string
+	minAge time.Duration
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func newFormTokens(secret string, minAge, maxAge time.Duration) *formTokens {
+	return &formTokens{
+		secret: secret,
+		minAge: minAge,
+		maxAge: maxAge,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// verify accepts a token which is signed, old enough, not expired and not submitted yet.
+// Tokens are not verified without the HMAC secret.
+func (f *formTokens) verify(token string, now time.Time) error {
+	if f.secret == "" {
+		return nil
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidFormToken
+	}
+	signature, err := hash.ComputeHmac256(parts[0]+"."+parts[1], f.secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(parts[2])) {
+		return errInvalidFormToken
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errInvalidFormToken
+	}
+
+	renderedAt := time.Unix(unix, 0)
+	if now.Sub(renderedAt) < f.minAge {
+		return errFormTooFast
+	}
+	expiresAt := renderedAt.Add(f.maxAge)
+	if f.maxAge > 0 && !now.Before(expiresAt) {
+		return errFormExpired
+	}
+	if f.maxAge <= 0 {
+		// the form never expires, its nonce is still forgotten so that the nonces do not grow without bound
+		expiresAt = now.Add(blog.DefaultMaxFormAge)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for nonce, t := range f.nonces {
+		if !now.Before(t) {
+			delete(f.nonces, nonce)
+		}
+	}
+	if _, ok := f.nonces[parts[1]]; ok {
+		return errFormReplayed
+	}
+	f.nonces[parts[1]] = expiresAt
+
+	return nil
+}
+
+// sweepInterval is the number of attempts after which the rate limiter removes the keys without recent attempts
+const sweepInterval = 100
+
+// rateLimiter allows limit attempts per window for each key
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	calls    int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string][]time.Time),
+	}
+}
+
+// allow records an attempt for key at now, it returns false if the limit of the window is reached
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	since := now.Add(-l.window)
+	l.calls++
+	if l.calls%sweepInterval == 0 {
+		l.sweep(since)
+	}
+
+	attempts := recentAttempts(l.attempts[key], since)
+	if len(attempts) >= l.limit {
+		l.attempts[key] = attempts
+		return false
+	}
+	l.attempts[key] = append(attempts, now)
+
+	return true
+}
+
+// sweep removes the keys without attempts after since
+func (l *rateLimiter) sweep(since time.Time) {
+	for k, attempts := range l.attempts {
+		if len(recentAttempts(attempts, since)) == 0 {
+			delete(l.attempts, k)
+		}
+	}
+}
+
+// parseTrustedProxies parses the IP addresses and the CIDR ranges of the trusted proxies
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy: %s", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// clientIP returns the IP address of the client which rate limits the subscriptions: the address of the connection,
+// or the address forwarded by a trusted proxy, which is the last address of X-Forwarded-For not being a trusted proxy
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.isTrustedProxy(net.ParseIP(host)) {
+		return host
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		if !s.isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); ip != nil {
+		return ip.String()
+	}
+
+	return host
+}
+
+func (s *Server) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range s.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recentAttempts returns the attempts after since, attempts are sorted by time
+func recentAttempts(attempts []time.Time, since time.Time) []time.Time {
+	for i, t := range attempts {
+		if t.After(since) {
+			return attempts[i:]
+		}
+	}
+
+	return nil
+}
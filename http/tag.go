@@ -4,10 +4,19 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/blog"
 )
 
 func (s *Server) tagHandler(w http.ResponseWriter, r *http.Request) error {
+	content := s.content()
+
+	lang, err := getLang(r)
+	if err != nil {
+		return err
+	}
+
 	tag := mux.Vars(r)["tagName"]
-	postsByTag := s.PostService.GetPostsByTag(tag)
-	return s.Renderer.RenderPosts(w, r, postsByTag)
+	postsByTag := content.PostService.GetPostsByTag(tag)
+	return content.Renderer.RenderPosts(w, r, blog.FilterByLang(postsByTag, lang))
 }
@@ -5,5 +5,5 @@ import (
 )
 
 func (s *Server) tagsHandler(w http.ResponseWriter, r *http.Request) error {
-	return s.Renderer.RenderTags(w)
+	return s.content().Renderer.RenderTags(w)
 }
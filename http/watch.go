@@ -0,0 +1,250 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+)
+
+const (
+	watchDebounce    = 200 * time.Millisecond
+	liveReloadPath   = "/livereload"
+	liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").onmessage = function () { location.reload(); };</script>`
+)
+
+// liveReload notifies connected browsers that the content has been changed
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func (lr *liveReload) subscribe() chan struct{} {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	c := make(chan struct{}, 1)
+	lr.clients[c] = struct{}{}
+	return c
+}
+
+func (lr *liveReload) unsubscribe(c chan struct{}) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	delete(lr.clients, c)
+}
+
+func (lr *liveReload) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	for c := range lr.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// EnableLiveReload registers the Server-Sent Events endpoint and injects a script into every HTML page
+// to refresh the open browser tabs when the content is reloaded by Watch
+func (s *Server) EnableLiveReload() {
+	s.liveReload = &liveReload{
+		clients: make(map[chan struct{}]struct{}),
+	}
+
+	s.newRoute(liveReloadPath, s.liveReloadHandler)
+	s.router.Use(injectLiveReloadScript)
+}
+
+func (s *Server) liveReloadHandler(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	c := s.liveReload.subscribe()
+	defer s.liveReload.unsubscribe(c)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-c:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func injectLiveReloadScript(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == liveReloadPath || strings.HasPrefix(r.URL.Path, "/static") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+		next.ServeHTTP(bw, r)
+
+		body := bw.buf.Bytes()
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		if strings.HasPrefix(contentType, "text/html") {
+			body = bytes.Replace(body, []byte("</body>"), []byte(liveReloadScript+"</body>"), 1)
+			w.Header().Del("Content-Length")
+		}
+
+		w.WriteHeader(bw.status)
+		_, _ = w.Write(body)
+	})
+}
+
+// Watch watches the posts directory, reloads the changed posts and refreshes the browsers until ctx is done
+func (s *Server) Watch(ctx context.Context, postsDir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create watcher")
+	}
+	defer watcher.Close()
+
+	if err := watchDirs(watcher, postsDir); err != nil {
+		return err
+	}
+
+	var (
+		changes = make(map[string]struct{})
+		timer   = time.NewTimer(watchDebounce)
+	)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			s.logger.Error().Err(err).Msg("error watching posts directory")
+		case event := <-watcher.Events:
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchDirs(watcher, event.Name); err != nil {
+						s.logger.Error().Err(err).Msg("error watching new directory")
+					}
+					continue
+				}
+			}
+			if filepath.Ext(event.Name) != markdown.Extension || event.Op == fsnotify.Chmod {
+				continue
+			}
+			changes[event.Name] = struct{}{}
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			if err := s.reloadFiles(ctx, postsDir, changes); err != nil {
+				s.logger.Error().Err(err).Msg("error reloading changed posts")
+			}
+			changes = make(map[string]struct{})
+		}
+	}
+}
+
+// reloadFiles re-parses the changed files, then reloads the posts and the search index
+func (s *Server) reloadFiles(ctx context.Context, postsDir string, names map[string]struct{}) error {
+	content := s.content()
+
+	var (
+		addedPosts    []*blog.Post
+		removedFiles  []string
+		modifiedPosts []*blog.Post
+	)
+	for name := range names {
+		uri := markdown.GetURI(postsDir, name)
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			if content.PostService.GetPostByURI(uri) != nil {
+				removedFiles = append(removedFiles, uri)
+			}
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to open file: %s", name)
+		}
+
+		post, err := markdown.Parse(ctx, s.converter, postsDir, f)
+		_ = f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse markdown: %s", name)
+		}
+
+		if content.PostService.GetPostByURI(uri) == nil {
+			addedPosts = append(addedPosts, post)
+		} else {
+			modifiedPosts = append(modifiedPosts, post)
+		}
+	}
+
+	if err := s.reload(addedPosts, removedFiles, modifiedPosts); err != nil {
+		return err
+	}
+
+	if s.liveReload != nil {
+		s.liveReload.broadcast()
+	}
+
+	return nil
+}
+
+func watchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return errors.Wrapf(err, "failed to watch %s", path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	postsDir := filepath.Join(t.TempDir(), "posts")
+	require.NoError(t, os.MkdirAll(filepath.Join(postsDir, "2020", "01", "02"), 0755))
+
+	config := &blog.Config{}
+	config.Env = "local"
+	config.Posts.Dir = postsDir
+	ws, err := NewServer(zerolog.Nop(), config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ws.SearchService.CloseIndex()
+	})
+	ws.EnableLiveReload()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = ws.Watch(ctx, postsDir)
+	}()
+
+	// the file is written again until it is picked up, since the watcher may not be running yet.
+	// The tick is longer than the debounce so that every write can be reloaded.
+	name := filepath.Join(postsDir, "2020", "01", "02", "watch.md")
+	require.Eventually(t, func() bool {
+		if ws.content().PostService.GetPostByURI("/2020/01/02/watch.md") != nil {
+			return true
+		}
+		assert.NoError(t, os.WriteFile(name, []byte(`---
+title: Watch
+date: 2020-01-02
+---
+Watch.`), 0644))
+		return false
+	}, 5*time.Second, 2*watchDebounce)
+
+	require.NoError(t, os.Remove(name))
+	assert.Eventually(t, func() bool {
+		return ws.content().PostService.GetPostByURI("/2020/01/02/watch.md") == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	ws.router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), liveReloadScript)
+}
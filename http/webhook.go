@@ -2,178 +2,301 @@ package http
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"path"
 	"sort"
-	"strings"
+	"time"
 
 	"github.com/quantonganh/blog"
 	"github.com/quantonganh/blog/markdown"
 )
 
-type webhookPayload struct {
-	Commits []struct {
-		ID        string   `json:"id"`
-		Message   string   `json:"message"`
-		Timestamp string   `json:"timestamp"`
-		Added     []string `json:"added"`
-		Removed   []string `json:"removed"`
-		Modified  []string `json:"modified"`
-	} `json:"commits"`
-}
-
 func (s *Server) webhookHandler(config *blog.Config) appHandler {
+	providers := newWebhookProviders(config)
 	return func(w http.ResponseWriter, r *http.Request) error {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			return err
 		}
 
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if err := verifySignature(signature, body, config.Webhook.Secret); err != nil {
+		var provider webhookProvider
+		for _, p := range providers {
+			if p.match(r) {
+				provider = p
+				break
+			}
+		}
+		if provider == nil {
+			return &Error{
+				Message: "unknown webhook provider",
+				Status:  http.StatusBadRequest,
+			}
+		}
+
+		if err := provider.verify(r, body); err != nil {
 			return &Error{
 				Message: err.Error(),
 				Status:  http.StatusUnauthorized,
 			}
 		}
 
-		var payload webhookPayload
-		if err := json.Unmarshal(body, &payload); err != nil {
-			return err
+		push, err := provider.parse(r, body)
+		if err != nil {
+			return &Error{
+				Cause:   err,
+				Message: "invalid webhook payload",
+				Status:  http.StatusBadRequest,
+			}
+		}
+		if push == nil || push.Branch != provider.branch() {
+			s.logger.Info().Str("provider", provider.name()).Msg("ignoring webhook which is not a push to the branch " + provider.branch())
+			return nil
 		}
 
-		cmd := exec.Command("sh", "-c", fmt.Sprintf("git -C %s fetch origin && git -C %s reset --hard origin/main", config.Posts.Dir, config.Posts.Dir))
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error fetching the updated content: %s: %w", string(output), err)
+		if s.DeliveryService == nil {
+			return s.processPush(r.Context(), push)
 		}
 
-		addedPosts, removedFiles, modifiedPosts, err := getChangedPosts(config, payload)
+		delivery, err := newDelivery(provider, r, body, push)
 		if err != nil {
 			return err
 		}
-
-		if err := s.reload(addedPosts, removedFiles, modifiedPosts); err != nil {
+		if err := s.acceptDelivery(delivery); err != nil {
+			if errors.Is(err, blog.ErrDeliveryExists) {
+				s.logger.Info().Str("delivery", delivery.ID).Msg("ignoring delivery which has already been received")
+				return nil
+			}
 			return err
 		}
 
-		for _, p := range addedPosts {
-			email := map[string]string{
-				"subject": p.Title,
-				"body":    string(p.Content),
-			}
-			data, err := json.Marshal(email)
-			if err != nil {
-				return err
-			}
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+}
 
-			if err := s.QueueService.Publish("added-posts", data); err != nil {
-				return err
+// processPush reloads the content with the push, then announces the added posts
+func (s *Server) processPush(ctx context.Context, push *pushEvent) error {
+	uris, err := s.reloadPush(ctx, push)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.announcePosts(uris)
+	return err
+}
+
+// reloadPush updates the content source, then reloads the changed posts and redirects the renamed ones.
+// It returns the URIs of the added posts to announce.
+func (s *Server) reloadPush(ctx context.Context, push *pushEvent) ([]string, error) {
+	content := s.content()
+	from := content.Version
+	if from == "" {
+		from = push.Before
+	}
+
+	if err := s.ContentSource.Update(ctx); err != nil {
+		return nil, err
+	}
+
+	fileChanges, version, err := s.diffContent(from, push)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := parseChanges(ctx, s.ContentSource.FS(), s.converter, fileChanges)
+	if err != nil {
+		return nil, err
+	}
+
+	// the redirects are added before the reload, so they are not lost if the reload fails and the push is processed again
+	if s.RedirectService != nil {
+		for _, change := range changes.renamed {
+			if err := s.RedirectService.AddRedirect("/"+change.From, "/"+change.To); err != nil {
+				return nil, err
 			}
 		}
+	}
+	for _, change := range changes.assets {
+		s.logger.Info().Str("action", change.Action).Str("from", change.From).Str("to", change.To).Msg("asset changed")
+	}
+
+	// the posts which are already served have been added by a previous push
+	var uris []string
+	for _, p := range changes.addedPosts {
+		if content.PostService.GetPostByURI(p.URI) == nil {
+			uris = append(uris, p.URI)
+		}
+	}
 
-		return nil
+	if err := s.reloadVersion(version, append(changes.addedPosts, changes.movedPosts...), changes.removedURIs, changes.modifiedPosts); err != nil {
+		return nil, err
 	}
+
+	return uris, nil
 }
 
-func verifySignature(signature string, payload []byte, secret string) error {
-	parts := strings.SplitN(signature, "=", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("X-Hub-Signature-256: expected 2 parts, got %d", len(parts))
+// announcePosts publishes the posts of the URIs which are still served, one at a time:
+// the URIs which are not announced yet are returned with the error so that they can be announced later
+func (s *Server) announcePosts(uris []string) ([]string, error) {
+	s.announceMu.Lock()
+	defer s.announceMu.Unlock()
+
+	last, err := s.lastAnnounced()
+	if err != nil {
+		return uris, err
 	}
 
-	algo, hash := parts[0], parts[1]
-	if algo != "sha256" {
-		return fmt.Errorf("X-Hub-Signature-256 algorithm: expected sha256, got %s", algo)
+	content := s.content()
+	for i, uri := range uris {
+		p := content.PostService.GetPostByURI(uri)
+		if p == nil {
+			continue
+		}
+		// the posts published since the last announcement are announced on schedule
+		if p.PublishDate.After(last) {
+			continue
+		}
+		if err := s.publishAddedPosts([]*blog.Post{p}); err != nil {
+			return uris[i:], err
+		}
 	}
 
-	hmacHash := hmac.New(sha256.New, []byte(secret))
-	_, err := hmacHash.Write(payload)
+	return nil, nil
+}
+
+// diffContent returns the files changed since the from version and the current version of the content source.
+// The commits of a versioned source are compared, otherwise the files listed in the payload are trusted.
+func (s *Server) diffContent(from string, push *pushEvent) ([]blog.ContentChange, string, error) {
+	source, ok := s.ContentSource.(blog.VersionedContentSource)
+	if !ok {
+		return push.changes(), "", nil
+	}
+
+	version, err := source.Version()
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	if from == "" || version == "" {
+		return push.changes(), version, nil
 	}
-	calculateHash := hex.EncodeToString(hmacHash.Sum(nil))
 
-	if !hmac.Equal([]byte(calculateHash), []byte(hash)) {
-		return errors.New("Request signature didn't match")
+	changes, err := source.Diff(from, version)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("from", from).Str("to", version).Msg("failed to diff the content, trusting the payload")
+		return push.changes(), version, nil
 	}
 
-	return nil
+	return changes, version, nil
 }
 
-func getChangedPosts(config *blog.Config, payload webhookPayload) ([]*blog.Post, []string, []*blog.Post, error) {
-	var (
-		addedFiles    []string
-		removedFiles  []string
-		modifiedFiles []string
-	)
-	for _, commit := range payload.Commits {
-		addedFiles = append(addedFiles, getMDFiles(commit.Added)...)
-		removedFiles = append(removedFiles, getMDFiles(commit.Removed)...)
-		modifiedFiles = append(modifiedFiles, getMDFiles(commit.Modified)...)
-	}
+// contentChanges are the posts and the assets changed by a push
+type contentChanges struct {
+	addedPosts []*blog.Post
+	// movedPosts are the renamed posts at their new URI
+	movedPosts    []*blog.Post
+	modifiedPosts []*blog.Post
+	// removedURIs are the URIs of the deleted posts and the previous URIs of the renamed ones
+	removedURIs []string
+	// renamed are the renamed posts and assets
+	renamed []blog.ContentChange
+	assets  []blog.ContentChange
+}
 
-	var (
-		addedPosts    []*blog.Post
-		modifiedPosts []*blog.Post
-	)
-	for _, file := range addedFiles {
-		name := filepath.Join(config.Posts.Dir, file)
-		f, err := os.Open(name)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("error opening new file %s: %w", name, err)
+// parseChanges parses the added, modified and renamed posts
+func parseChanges(ctx context.Context, fsys fs.FS, converter markdown.Converter, fileChanges []blog.ContentChange) (*contentChanges, error) {
+	changes := new(contentChanges)
+	for _, change := range fileChanges {
+		if change.Action == blog.ContentRenamed {
+			changes.renamed = append(changes.renamed, change)
+		}
+		if !isPost(change.From) && !isPost(change.To) {
+			changes.assets = append(changes.assets, change)
+			continue
+		}
+
+		if isPost(change.From) && change.Action != blog.ContentModified {
+			changes.removedURIs = append(changes.removedURIs, "/"+change.From)
+		}
+		if !isPost(change.To) {
+			continue
 		}
 
-		newPost, err := markdown.Parse(context.Background(), config.Posts.Dir, f)
+		p, err := markdown.ParseFile(ctx, converter, fsys, change.To)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
+		switch change.Action {
+		case blog.ContentAdded:
+			changes.addedPosts = append(changes.addedPosts, p)
+		case blog.ContentModified:
+			changes.modifiedPosts = append(changes.modifiedPosts, p)
+		case blog.ContentRenamed:
+			if isPost(change.From) {
+				changes.movedPosts = append(changes.movedPosts, p)
+			} else {
+				changes.addedPosts = append(changes.addedPosts, p)
+			}
+		}
+	}
 
-		addedPosts = append(addedPosts, newPost)
+	return changes, nil
+}
+
+func isPost(name string) bool {
+	return path.Ext(name) == markdown.Extension
+}
+
+// publishAddedPosts sends a message for each newly listed post to the "added-posts" queue
+func (s *Server) publishAddedPosts(addedPosts []*blog.Post) error {
+	if s.QueueService == nil {
+		return nil
 	}
 
-	for _, file := range modifiedFiles {
-		name := filepath.Join(config.Posts.Dir, file)
-		f, err := os.Open(name)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("error opening modified file %s: %w", name, err)
+	now := time.Now()
+	for _, p := range addedPosts {
+		if !p.IsListed(now) {
+			continue
 		}
 
-		modifiedPost, err := markdown.Parse(context.Background(), config.Posts.Dir, f)
+		email := map[string]string{
+			"subject": p.Title,
+			"body":    string(p.Content),
+		}
+		data, err := json.Marshal(email)
 		if err != nil {
-			return nil, nil, nil, err
+			return err
 		}
 
-		modifiedPosts = append(modifiedPosts, modifiedPost)
-	}
-
-	return addedPosts, removedFiles, modifiedPosts, nil
-}
-
-func getMDFiles(files []string) []string {
-	var mdFiles []string
-	for _, file := range files {
-		if strings.HasSuffix(file, markdown.Extension) {
-			mdFiles = append(mdFiles, file)
+		if err := s.QueueService.Publish("added-posts", data); err != nil {
+			return err
 		}
 	}
 
-	return mdFiles
+	return nil
 }
 
+// reload builds a new version of the content with the changes applied, then swaps it with the current one:
+// the requests being served keep reading the previous version, which is never modified
 func (s *Server) reload(addedPosts []*blog.Post, removedFiles []string, modifiedPosts []*blog.Post) error {
-	if s.PostService != nil {
-		posts := s.PostService.GetAllPosts()
+	return s.reloadVersion("", addedPosts, removedFiles, modifiedPosts)
+}
+
+// reloadVersion reloads the changes read from a version of the content source, the current version is kept if it is empty
+func (s *Server) reloadVersion(version string, addedPosts []*blog.Post, removedFiles []string, modifiedPosts []*blog.Post) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	var publishedPosts []*blog.Post
+	if content := s.content(); content != nil {
+		var posts []*blog.Post
+		posts = append(posts, content.PostService.GetAllPosts()...)
+		posts = append(posts, content.PostService.GetUnlistedPosts()...)
+		posts = append(posts, content.PostService.GetScheduledPosts()...)
 		updatedPosts, err := updatePosts(posts, addedPosts, removedFiles, modifiedPosts)
 		if err != nil {
 			return err
@@ -181,7 +304,18 @@ func (s *Server) reload(addedPosts []*blog.Post, removedFiles []string, modified
 		sort.Slice(updatedPosts, func(i, j int) bool {
 			return updatedPosts[i].Date.Time.After(updatedPosts[j].Date.Time)
 		})
-		s.PostService = markdown.NewPostService(updatedPosts)
+		if version == "" {
+			version = content.Version
+		}
+		next := newSnapshot(s.config, updatedPosts, s.recommender)
+		next.Version = version
+		// the scheduled posts whose publish date has passed are listed by any reload, whatever its changes
+		for _, p := range content.PostService.GetScheduledPosts() {
+			if post := next.PostService.GetPostByURI(p.URI); post != nil && !post.Unlisted {
+				publishedPosts = append(publishedPosts, post)
+			}
+		}
+		s.current.Store(next)
 	}
 
 	if s.SearchService != nil {
@@ -194,7 +328,21 @@ func (s *Server) reload(addedPosts []*blog.Post, removedFiles []string, modified
 			}
 		}
 
+		now := time.Now()
 		for _, post := range append(addedPosts, modifiedPosts...) {
+			// drafts, scheduled and unlisted posts must not show up in the search results
+			if !post.IsListed(now) {
+				if err := index.Delete(post.URI); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := s.SearchService.Index(post, batch); err != nil {
+				return err
+			}
+		}
+		for _, post := range publishedPosts {
 			if err := s.SearchService.Index(post, batch); err != nil {
 				return err
 			}
@@ -209,24 +357,31 @@ func (s *Server) reload(addedPosts []*blog.Post, removedFiles []string, modified
 	return nil
 }
 
+// updatePosts returns a new slice of posts with the changes applied, drafts are left out
 func updatePosts(posts []*blog.Post, addedPosts []*blog.Post, removedFiles []string, modifiedPosts []*blog.Post) ([]*blog.Post, error) {
-	posts = append(posts, addedPosts...)
+	var (
+		m    = make(map[string]*blog.Post, len(posts)+len(addedPosts))
+		uris []string
+	)
+	for _, post := range append(append(posts[:len(posts):len(posts)], addedPosts...), modifiedPosts...) {
+		if _, found := m[post.URI]; !found {
+			uris = append(uris, post.URI)
+		}
+		m[post.URI] = post
+	}
 
 	for _, name := range removedFiles {
-		for i, post := range posts {
-			if post.URI == name {
-				posts = append(posts[:i], posts[i+1:]...)
-			}
-		}
+		delete(m, name)
 	}
 
-	for _, p := range modifiedPosts {
-		for i, post := range posts {
-			if post.URI == p.URI {
-				posts[i] = p
-			}
+	updatedPosts := make([]*blog.Post, 0, len(m))
+	for _, uri := range uris {
+		post, found := m[uri]
+		if !found || post.Draft {
+			continue
 		}
+		updatedPosts = append(updatedPosts, post)
 	}
 
-	return posts, nil
+	return updatedPosts, nil
 }
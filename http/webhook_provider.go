@@ -0,0 +1,368 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	defaultGenericHeader  = "X-Signature"
+	genericDeliveryHeader = "X-Delivery-ID"
+	branchRefPrefix       = "refs/heads/"
+)
+
+// pushEvent is a push normalized from the payload of a webhook provider
+type pushEvent struct {
+	Branch   string
+	Before   string
+	After    string
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// webhookProvider verifies and decodes the webhooks sent by a git hosting service
+type webhookProvider interface {
+	// name returns the name of the provider
+	name() string
+	// match reports whether the request is sent by the provider
+	match(r *http.Request) bool
+	// verify checks the signature or the token of the request
+	verify(r *http.Request, body []byte) error
+	// parse returns the push of the payload, nil if the event is not a push
+	parse(r *http.Request, body []byte) (*pushEvent, error)
+	// branch returns the branch whose pushes are processed
+	branch() string
+	// deliveryID returns the ID of the delivery sent by the provider, it is kept when the delivery is sent again
+	deliveryID(r *http.Request) string
+}
+
+// newWebhookProviders returns the enabled providers in the order they are matched:
+// Gitea is tried before GitHub since it sends the GitHub headers too
+func newWebhookProviders(config *blog.Config) []webhookProvider {
+	branch := config.PushBranch
+
+	var providers []webhookProvider
+	if gitea := config.Webhook.Gitea; gitea.Secret != "" {
+		providers = append(providers, &giteaProvider{secret: gitea.Secret, pushBranch: branch(gitea)})
+	}
+	if gitlab := config.Webhook.GitLab; gitlab.Secret != "" {
+		providers = append(providers, &gitlabProvider{token: gitlab.Secret, pushBranch: branch(gitlab)})
+	}
+	github := config.Webhook.GitHub
+	if github.Secret == "" {
+		github.Secret = config.Webhook.Secret
+	}
+	if github.Secret != "" {
+		providers = append(providers, &githubProvider{secret: github.Secret, pushBranch: branch(github)})
+	}
+	if generic := config.Webhook.Generic; generic.Secret != "" {
+		header := generic.Header
+		if header == "" {
+			header = defaultGenericHeader
+		}
+		providers = append(providers, &genericProvider{secret: generic.Secret, header: header, pushBranch: branch(generic)})
+	}
+
+	return providers
+}
+
+// commitsPayload is the part of the push payloads of GitHub, Gitea and GitLab listing the changed files
+type commitsPayload struct {
+	Ref     string `json:"ref"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Commits []struct {
+		ID       string   `json:"id"`
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+}
+
+// parseCommits replays the commits in order, so a file added then removed in the same push is left out
+// and a file removed then added again is modified
+func parseCommits(body []byte) (*pushEvent, error) {
+	var payload commitsPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	const (
+		unchanged = iota
+		added
+		removed
+		modified
+	)
+	var (
+		states = make(map[string]int)
+		files  []string
+	)
+	setState := func(file string, state int) {
+		if _, found := states[file]; !found {
+			files = append(files, file)
+		}
+		states[file] = state
+	}
+	for _, commit := range payload.Commits {
+		for _, file := range commit.Added {
+			if states[file] == removed {
+				setState(file, modified)
+			} else {
+				setState(file, added)
+			}
+		}
+		for _, file := range commit.Modified {
+			if states[file] != added {
+				setState(file, modified)
+			}
+		}
+		for _, file := range commit.Removed {
+			if states[file] == added {
+				setState(file, unchanged)
+			} else {
+				setState(file, removed)
+			}
+		}
+	}
+
+	push := &pushEvent{
+		Branch: branchOf(payload.Ref),
+		Before: payload.Before,
+		After:  payload.After,
+	}
+	for _, file := range files {
+		switch states[file] {
+		case added:
+			push.Added = append(push.Added, file)
+		case removed:
+			push.Removed = append(push.Removed, file)
+		case modified:
+			push.Modified = append(push.Modified, file)
+		}
+	}
+
+	return push, nil
+}
+
+// changes returns the files listed in the payload, the renamed files are listed as removed then added
+func (push *pushEvent) changes() []blog.ContentChange {
+	var changes []blog.ContentChange
+	for _, file := range push.Added {
+		changes = append(changes, blog.ContentChange{Action: blog.ContentAdded, To: file})
+	}
+	for _, file := range push.Modified {
+		changes = append(changes, blog.ContentChange{Action: blog.ContentModified, From: file, To: file})
+	}
+	for _, file := range push.Removed {
+		changes = append(changes, blog.ContentChange{Action: blog.ContentDeleted, From: file})
+	}
+
+	return changes
+}
+
+// branchOf returns the branch of a ref, it is empty if the ref is not a branch such as a tag
+func branchOf(ref string) string {
+	if !strings.HasPrefix(ref, branchRefPrefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(ref, branchRefPrefix)
+}
+
+type githubProvider struct {
+	secret     string
+	pushBranch string
+}
+
+func (p *githubProvider) name() string {
+	return "github"
+}
+
+func (p *githubProvider) match(r *http.Request) bool {
+	return r.Header.Get("X-Hub-Signature-256") != ""
+}
+
+func (p *githubProvider) verify(r *http.Request, body []byte) error {
+	return verifySignature(r.Header.Get("X-Hub-Signature-256"), body, p.secret)
+}
+
+func (p *githubProvider) parse(r *http.Request, body []byte) (*pushEvent, error) {
+	if event := r.Header.Get("X-GitHub-Event"); event != "" && event != "push" {
+		return nil, nil
+	}
+
+	return parseCommits(body)
+}
+
+func (p *githubProvider) branch() string {
+	return p.pushBranch
+}
+
+func (p *githubProvider) deliveryID(r *http.Request) string {
+	return r.Header.Get("X-GitHub-Delivery")
+}
+
+type giteaProvider struct {
+	secret     string
+	pushBranch string
+}
+
+func (p *giteaProvider) name() string {
+	return "gitea"
+}
+
+func (p *giteaProvider) match(r *http.Request) bool {
+	return r.Header.Get("X-Gitea-Signature") != ""
+}
+
+func (p *giteaProvider) verify(r *http.Request, body []byte) error {
+	return verifyHMAC(r.Header.Get("X-Gitea-Signature"), body, p.secret)
+}
+
+func (p *giteaProvider) parse(r *http.Request, body []byte) (*pushEvent, error) {
+	if event := r.Header.Get("X-Gitea-Event"); event != "" && event != "push" {
+		return nil, nil
+	}
+
+	return parseCommits(body)
+}
+
+func (p *giteaProvider) branch() string {
+	return p.pushBranch
+}
+
+func (p *giteaProvider) deliveryID(r *http.Request) string {
+	return r.Header.Get("X-Gitea-Delivery")
+}
+
+type gitlabProvider struct {
+	token      string
+	pushBranch string
+}
+
+func (p *gitlabProvider) name() string {
+	return "gitlab"
+}
+
+func (p *gitlabProvider) match(r *http.Request) bool {
+	return r.Header.Get("X-Gitlab-Token") != ""
+}
+
+// verify compares the secret token, GitLab does not sign the payload
+func (p *gitlabProvider) verify(r *http.Request, body []byte) error {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(p.token)) != 1 {
+		return errors.New("X-Gitlab-Token didn't match")
+	}
+
+	return nil
+}
+
+func (p *gitlabProvider) parse(r *http.Request, body []byte) (*pushEvent, error) {
+	if event := r.Header.Get("X-Gitlab-Event"); event != "" && event != "Push Hook" {
+		return nil, nil
+	}
+
+	return parseCommits(body)
+}
+
+func (p *gitlabProvider) branch() string {
+	return p.pushBranch
+}
+
+func (p *gitlabProvider) deliveryID(r *http.Request) string {
+	return r.Header.Get("X-Gitlab-Event-UUID")
+}
+
+// genericProvider accepts the webhooks signed by an HMAC-SHA256 of the payload in a header,
+// the payload is either a push listing its commits or the already normalized lists of files:
+//
+//	{"ref": "refs/heads/main", "added": [], "removed": [], "modified": []}
+type genericProvider struct {
+	secret     string
+	header     string
+	pushBranch string
+}
+
+func (p *genericProvider) name() string {
+	return "generic"
+}
+
+func (p *genericProvider) match(r *http.Request) bool {
+	return r.Header.Get(p.header) != ""
+}
+
+func (p *genericProvider) verify(r *http.Request, body []byte) error {
+	return verifyHMAC(strings.TrimPrefix(r.Header.Get(p.header), "sha256="), body, p.secret)
+}
+
+func (p *genericProvider) parse(r *http.Request, body []byte) (*pushEvent, error) {
+	var payload struct {
+		commitsPayload
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Commits) > 0 {
+		return parseCommits(body)
+	}
+
+	return &pushEvent{
+		Branch:   branchOf(payload.Ref),
+		Before:   payload.Before,
+		After:    payload.After,
+		Added:    payload.Added,
+		Removed:  payload.Removed,
+		Modified: payload.Modified,
+	}, nil
+}
+
+func (p *genericProvider) branch() string {
+	return p.pushBranch
+}
+
+func (p *genericProvider) deliveryID(r *http.Request) string {
+	return r.Header.Get(genericDeliveryHeader)
+}
+
+func verifySignature(signature string, payload []byte, secret string) error {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("X-Hub-Signature-256: expected 2 parts, got %d", len(parts))
+	}
+
+	algo, hash := parts[0], parts[1]
+	if algo != "sha256" {
+		return fmt.Errorf("X-Hub-Signature-256 algorithm: expected sha256, got %s", algo)
+	}
+
+	return verifyHMAC(hash, payload, secret)
+}
+
+// verifyHMAC checks the hex encoded HMAC-SHA256 of the payload
+func verifyHMAC(hash string, payload []byte, secret string) error {
+	hmacHash := hmac.New(sha256.New, []byte(secret))
+	_, err := hmacHash.Write(payload)
+	if err != nil {
+		return err
+	}
+	calculateHash := hex.EncodeToString(hmacHash.Sum(nil))
+
+	if !hmac.Equal([]byte(calculateHash), []byte(hash)) {
+		return errors.New("Request signature didn't match")
+	}
+
+	return nil
+}
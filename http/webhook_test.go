@@ -0,0 +1,303 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/markdown"
+	"github.com/quantonganh/blog/source"
+	"github.com/quantonganh/blog/sqlite"
+)
+
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookServer(t *testing.T, config *blog.Config) *Server {
+	config.Env = "production"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	ss, err := NewServer(zerolog.Nop(), config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+	ss.ContentSource = source.NewFS(fstest.MapFS{
+		"2021/01/01/hello.md":  &fstest.MapFile{Data: []byte("---\ntitle: Hello\ndate: 2021-01-01\n---\nHello from the content source.")},
+		"2021/01/01/hello.png": &fstest.MapFile{Data: []byte("png")},
+		"2021/01/02/world.md":  &fstest.MapFile{Data: []byte("---\ntitle: World\ndate: 2021-01-02\n---\nWorld.")},
+	})
+
+	return ss
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	config := &blog.Config{}
+	config.Webhook.Secret = "secret"
+	ss := newWebhookServer(t, config)
+
+	payload := []byte(`{"ref": "refs/heads/main", "commits": [{"added": ["2021/01/01/hello.md", "2021/01/01/hello.png"]}]}`)
+	request, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	require.NoError(t, err)
+	request.Header.Set("X-Hub-Signature-256", "sha256="+sign(config.Webhook.Secret, payload))
+	rr := httptest.NewRecorder()
+	ss.router.ServeHTTP(rr, request)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	p := ss.content().PostService.GetPostByURI("/2021/01/01/hello.md")
+	require.NotNil(t, p)
+	assert.Equal(t, "Hello", p.Title)
+
+	for url, contains := range map[string]string{
+		"/2021/01/01/hello":     "Hello from the content source.",
+		"/2021/01/01/hello.png": "png",
+	} {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		ss.router.ServeHTTP(rr, request)
+		assert.Equal(t, http.StatusOK, rr.Code, url)
+		assert.Contains(t, rr.Body.String(), contains, url)
+	}
+}
+
+func TestWebhookProviders(t *testing.T) {
+	t.Parallel()
+
+	config := &blog.Config{}
+	config.Webhook.GitHub.Secret = "github"
+	config.Webhook.Gitea.Secret = "gitea"
+	config.Webhook.Gitea.Branch = "production"
+	config.Webhook.GitLab.Secret = "gitlab"
+	config.Webhook.Generic.Secret = "generic"
+	config.Webhook.Generic.Header = "X-Blog-Signature"
+
+	var (
+		push           = []byte(`{"ref": "refs/heads/main", "commits": [{"added": ["2021/01/01/hello.md"]}]}`)
+		productionPush = []byte(`{"ref": "refs/heads/production", "commits": [{"added": ["2021/01/01/hello.md"]}]}`)
+		genericPush    = []byte(`{"ref": "refs/heads/main", "added": ["2021/01/01/hello.md"]}`)
+	)
+	for name, tc := range map[string]struct {
+		payload []byte
+		headers map[string]string
+		status  int
+		added   bool
+	}{
+		"github": {
+			payload: push,
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("github", push)},
+			status:  http.StatusOK,
+			added:   true,
+		},
+		"github ping": {
+			payload: push,
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + sign("github", push)},
+			status:  http.StatusOK,
+		},
+		"gitea": {
+			payload: productionPush,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("gitea", productionPush), "X-Hub-Signature-256": "sha256=" + sign("gitea", productionPush)},
+			status:  http.StatusOK,
+			added:   true,
+		},
+		"gitea other branch": {
+			payload: push,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("gitea", push)},
+			status:  http.StatusOK,
+		},
+		"gitea invalid signature": {
+			payload: productionPush,
+			headers: map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("github", productionPush)},
+			status:  http.StatusUnauthorized,
+		},
+		"gitlab": {
+			payload: push,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "gitlab"},
+			status:  http.StatusOK,
+			added:   true,
+		},
+		"gitlab invalid token": {
+			payload: push,
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "github"},
+			status:  http.StatusUnauthorized,
+		},
+		"generic": {
+			payload: genericPush,
+			headers: map[string]string{"X-Blog-Signature": "sha256=" + sign("generic", genericPush)},
+			status:  http.StatusOK,
+			added:   true,
+		},
+		"unknown": {
+			payload: push,
+			status:  http.StatusBadRequest,
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ss := newWebhookServer(t, config)
+			request, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(tc.payload))
+			require.NoError(t, err)
+			for key, value := range tc.headers {
+				request.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			ss.router.ServeHTTP(rr, request)
+			require.Equal(t, tc.status, rr.Code, rr.Body.String())
+			assert.Equal(t, tc.added, ss.content().PostService.GetPostByURI("/2021/01/01/hello.md") != nil)
+		})
+	}
+}
+
+func TestParseCommits(t *testing.T) {
+	t.Parallel()
+
+	push, err := parseCommits([]byte(`{
+  "ref": "refs/heads/main",
+  "commits": [
+    {"added": ["added.md", "temporary.md", "readded.md"], "modified": ["modified.md"], "removed": ["removed.md"]},
+    {"added": ["removed.md"], "modified": ["added.md"], "removed": ["temporary.md", "readded.md"]},
+    {"added": ["readded.md"]}
+  ]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, &pushEvent{
+		Branch:   "main",
+		Added:    []string{"added.md", "readded.md"},
+		Modified: []string{"modified.md", "removed.md"},
+	}, push)
+
+	push, err = parseCommits([]byte(`{"ref": "refs/tags/v1.0.0"}`))
+	require.NoError(t, err)
+	assert.Empty(t, push.Branch)
+}
+
+func TestWebhookDiff(t *testing.T) {
+	t.Parallel()
+
+	workDir := filepath.Join(t.TempDir(), "blog-posts")
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	commit := func(files map[string]string, moves map[string]string) string {
+		for name, content := range files {
+			name := filepath.Join(workDir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+			require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+		}
+		for from, to := range moves {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(workDir, filepath.FromSlash(to))), 0755))
+			_, err := wt.Move(from, to)
+			require.NoError(t, err)
+		}
+		require.NoError(t, wt.AddWithOptions(&git.AddOptions{All: true}))
+		hash, err := wt.Commit("update", &git.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash.String()
+	}
+	v1 := commit(map[string]string{
+		"2021/01/01/hello.md":  "---\ntitle: Hello\ndate: 2021-01-01\n---\nHello.",
+		"2021/01/01/photo.png": "png",
+	}, nil)
+
+	bareDir := filepath.Join(t.TempDir(), "blog-posts.git")
+	_, err = git.PlainClone(bareDir, true, &git.CloneOptions{URL: workDir})
+	require.NoError(t, err)
+	contentSource, err := source.NewGit(bareDir, "master")
+	require.NoError(t, err)
+	converter, err := markdown.NewConverter(&blog.Config{})
+	require.NoError(t, err)
+	posts, err := markdown.GetAllPosts(contentSource, converter)
+	require.NoError(t, err)
+
+	config := &blog.Config{}
+	config.Env = "production"
+	config.Posts.Dir = filepath.Join(t.TempDir(), "posts")
+	config.Webhook.Secret = "secret"
+	config.Webhook.Branch = "master"
+	ss, err := NewServer(zerolog.Nop(), config, posts)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.SearchService.CloseIndex()
+	})
+	ss.ContentSource = contentSource
+	db := sqlite.NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	ss.RedirectService = sqlite.NewRedirectService(db)
+
+	// the payload does not list the files, they are found by comparing the commits
+	push := func(before, after string) {
+		payload := []byte(fmt.Sprintf(`{"ref": "refs/heads/master", "before": %q, "after": %q, "commits": []}`, before, after))
+		request, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+		require.NoError(t, err)
+		request.Header.Set("X-Hub-Signature-256", "sha256="+sign(config.Webhook.Secret, payload))
+		rr := httptest.NewRecorder()
+		ss.router.ServeHTTP(rr, request)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		ss.router.ServeHTTP(rr, request)
+		return rr
+	}
+
+	v2 := commit(map[string]string{
+		"2021/01/02/new.md": "---\ntitle: New\ndate: 2021-01-02\n---\nNew.",
+	}, map[string]string{
+		"2021/01/01/hello.md":  "2021/02/01/hello.md",
+		"2021/01/01/photo.png": "2021/02/01/photo.png",
+	})
+	push(v1, v2)
+
+	postService := ss.content().PostService
+	assert.Nil(t, postService.GetPostByURI("/2021/01/01/hello.md"))
+	assert.NotNil(t, postService.GetPostByURI("/2021/02/01/hello.md"))
+	assert.NotNil(t, postService.GetPostByURI("/2021/01/02/new.md"))
+	assert.Equal(t, v2, ss.content().Version)
+	for from, to := range map[string]string{
+		"/2021/01/01/hello":     "/2021/02/01/hello",
+		"/2021/01/01/hello.md":  "/2021/02/01/hello.md",
+		"/2021/01/01/photo.png": "/2021/02/01/photo.png",
+	} {
+		rr := get(from)
+		assert.Equal(t, http.StatusMovedPermanently, rr.Code, from)
+		assert.Equal(t, to, rr.Header().Get("Location"), from)
+	}
+	assert.Equal(t, http.StatusOK, get("/2021/02/01/photo.png").Code)
+
+	// the content is compared from the version it was read from, even if the payload misses it
+	require.NoError(t, os.Remove(filepath.Join(workDir, "2021", "01", "02", "new.md")))
+	v3 := commit(nil, nil)
+	push("", v3)
+	assert.Nil(t, ss.content().PostService.GetPostByURI("/2021/01/02/new.md"))
+	assert.Equal(t, http.StatusNotFound, get("/2021/01/02/new").Code)
+}
@@ -0,0 +1,91 @@
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/Depado/bfchroma"
+	bf "github.com/russross/blackfriday/v2"
+
+	"github.com/quantonganh/blog"
+)
+
+type blackfridayConverter struct {
+	highlighter *highlighter
+}
+
+func newBlackfridayConverter(h *highlighter) Converter {
+	return &blackfridayConverter{
+		highlighter: h,
+	}
+}
+
+// newRenderer returns a new renderer for each document since the HTML renderer keeps track of the heading ids
+// and is not safe for concurrent use
+func (c *blackfridayConverter) newRenderer() bf.Renderer {
+	// remove SmartypantsFractions
+	htmlFlags := bf.UseXHTML | bf.Smartypants | bf.SmartypantsDashes | bf.SmartypantsLatexDashes
+	return bfchroma.NewRenderer(
+		bfchroma.WithoutAutodetect(),
+		bfchroma.ChromaOptions(c.highlighter.options...),
+		bfchroma.ChromaStyle(c.highlighter.style),
+		bfchroma.Extend(bf.NewHTMLRenderer(bf.HTMLRendererParameters{
+			Flags: htmlFlags,
+		})),
+	)
+}
+
+func (c *blackfridayConverter) Convert(source []byte) (*Document, error) {
+	var (
+		node = bf.New(bf.WithExtensions(bf.CommonExtensions)).Parse(source)
+		ids  = make(headingIDs)
+		toc  []*blog.Heading
+	)
+	node.Walk(func(n *bf.Node, entering bool) bf.WalkStatus {
+		if !entering || n.Type != bf.Heading || n.IsTitleblock {
+			return bf.GoToNext
+		}
+
+		text := blackfridayText(n)
+		if n.HeadingID == "" {
+			n.HeadingID = ids.generate(text)
+		} else {
+			n.HeadingID = ids.generate(n.HeadingID)
+		}
+		toc = append(toc, &blog.Heading{
+			Level: n.Level,
+			Text:  text,
+			Slug:  n.HeadingID,
+		})
+		return bf.SkipChildren
+	})
+
+	var (
+		buf      bytes.Buffer
+		renderer = c.newRenderer()
+	)
+	renderer.RenderHeader(&buf, node)
+	node.Walk(func(n *bf.Node, entering bool) bf.WalkStatus {
+		return renderer.RenderNode(&buf, n, entering)
+	})
+	renderer.RenderFooter(&buf, node)
+
+	return &Document{
+		HTML: template.HTML(buf.String()),
+		TOC:  toc,
+	}, nil
+}
+
+// blackfridayText returns the plain text of the inline nodes
+func blackfridayText(node *bf.Node) string {
+	var b strings.Builder
+	node.Walk(func(n *bf.Node, entering bool) bf.WalkStatus {
+		if entering && (n.Type == bf.Text || n.Type == bf.Code) {
+			b.Write(n.Literal)
+		}
+		return bf.GoToNext
+	})
+
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	// Blackfriday is the name of the blackfriday v2 converter
+	Blackfriday = "blackfriday"
+	// Goldmark is the name of the CommonMark compliant goldmark converter
+	Goldmark = "goldmark"
+
+	defaultStyle    = "solarized-dark"
+	defaultTabWidth = 4
+)
+
+// Converter is the interface that wraps the method to convert markdown into HTML
+type Converter interface {
+	Convert(source []byte) (*Document, error)
+}
+
+// Document is the converted HTML with the table of contents built from its headings
+type Document struct {
+	HTML template.HTML
+	TOC  []*blog.Heading
+}
+
+// NewConverter returns the converter configured in config.Markdown.Renderer, blackfriday is used by default
+func NewConverter(config *blog.Config) (Converter, error) {
+	h := newHighlighter(config)
+	switch config.Markdown.Renderer {
+	case "", Blackfriday:
+		return newBlackfridayConverter(h), nil
+	case Goldmark:
+		return newGoldmarkConverter(h), nil
+	default:
+		return nil, errors.Errorf("unknown markdown renderer: %s", config.Markdown.Renderer)
+	}
+}
+
+// highlighter highlights code blocks with chroma
+type highlighter struct {
+	style     *chroma.Style
+	options   []html.Option
+	formatter *html.Formatter
+}
+
+func newHighlighter(config *blog.Config) *highlighter {
+	name := config.Markdown.Highlight.Style
+	if name == "" {
+		name = defaultStyle
+	}
+	tabWidth := config.Markdown.Highlight.TabWidth
+	if tabWidth == 0 {
+		tabWidth = defaultTabWidth
+	}
+
+	options := []html.Option{
+		html.WithLineNumbers(config.Markdown.Highlight.LineNumbers),
+		html.TabWidth(tabWidth),
+	}
+
+	return &highlighter{
+		style:     styles.Get(name),
+		options:   options,
+		formatter: html.New(options...),
+	}
+}
+
+// highlight writes the highlighted code to w, the language is not guessed if it is not given
+func (h *highlighter) highlight(w io.Writer, lang, code string) error {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+
+	return h.formatter.Format(w, h.style, iterator)
+}
@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestNewConverter(t *testing.T) {
+	config := &blog.Config{}
+	c, err := NewConverter(config)
+	require.NoError(t, err)
+	assert.IsType(t, &blackfridayConverter{}, c)
+
+	config.Markdown.Renderer = Goldmark
+	c, err = NewConverter(config)
+	require.NoError(t, err)
+	assert.IsType(t, &goldmarkConverter{}, c)
+
+	config.Markdown.Renderer = "unknown"
+	_, err = NewConverter(config)
+	assert.Error(t, err)
+}
+
+func TestGoldmarkConverter(t *testing.T) {
+	config := &blog.Config{}
+	config.Markdown.Renderer = Goldmark
+	c, err := NewConverter(config)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		source   string
+		contains []string
+	}{
+		"table": {
+			source:   "| a | b |\n|---|---|\n| 1 | 2 |\n",
+			contains: []string{"<table>", "<th>a</th>", "<td>2</td>"},
+		},
+		"task list": {
+			source:   "- [x] done\n- [ ] todo\n",
+			contains: []string{`<input checked="" disabled="" type="checkbox" />`, `<input disabled="" type="checkbox" />`},
+		},
+		"footnote": {
+			source:   "Text[^1].\n\n[^1]: Note.\n",
+			contains: []string{`<sup id="fnref:1">`, `<li id="fn:1">`},
+		},
+		"definition list": {
+			source:   "Term\n: Definition\n",
+			contains: []string{"<dl>", "<dt>Term</dt>", "<dd>Definition</dd>"},
+		},
+		"heading id": {
+			source:   "## Hello World\n",
+			contains: []string{`<h2 id="hello-world">Hello World</h2>`},
+		},
+		"code block": {
+			source:   "```go\npackage main\n```\n",
+			contains: []string{`<pre style="color:#93a1a1;background-color:#002b36;`, `<span style="color:#719e07">package</span>`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, err := c.Convert([]byte(tc.source))
+			require.NoError(t, err)
+			for _, s := range tc.contains {
+				assert.Contains(t, string(doc.HTML), s)
+			}
+		})
+	}
+}
+
+func TestTOC(t *testing.T) {
+	source := []byte("# Giới thiệu\n\n## Cài đặt `blog`\n\n### Hello, World!\n\n## Cài đặt blog\n")
+	for _, renderer := range []string{Blackfriday, Goldmark} {
+		t.Run(renderer, func(t *testing.T) {
+			config := &blog.Config{}
+			config.Markdown.Renderer = renderer
+			c, err := NewConverter(config)
+			require.NoError(t, err)
+
+			doc, err := c.Convert(source)
+			require.NoError(t, err)
+			assert.Equal(t, []*blog.Heading{
+				{Level: 1, Text: "Giới thiệu", Slug: "giới-thiệu"},
+				{Level: 2, Text: "Cài đặt blog", Slug: "cài-đặt-blog"},
+				{Level: 3, Text: "Hello, World!", Slug: "hello-world"},
+				{Level: 2, Text: "Cài đặt blog", Slug: "cài-đặt-blog-1"},
+			}, doc.TOC)
+			assert.Contains(t, string(doc.HTML), `<h3 id="hello-world">Hello, World!</h3>`)
+			assert.Contains(t, string(doc.HTML), `<h2 id="cài-đặt-blog-1">Cài đặt blog</h2>`)
+		})
+	}
+}
+
+func TestConvertConcurrently(t *testing.T) {
+	for _, renderer := range []string{Blackfriday, Goldmark} {
+		config := &blog.Config{}
+		config.Markdown.Renderer = renderer
+		c, err := NewConverter(config)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				doc, err := c.Convert([]byte("# Title\n\n```go\npackage main\n```\n"))
+				assert.NoError(t, err)
+				assert.Contains(t, string(doc.HTML), "package")
+			}()
+		}
+		wg.Wait()
+	}
+}
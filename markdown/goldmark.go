@@ -0,0 +1,137 @@
+package markdown
+
+import (
+	"bytes"
+	stdhtml "html"
+	"html/template"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/quantonganh/blog"
+)
+
+// codeBlockPriority makes the code block renderer take precedence over the default HTML renderer
+const codeBlockPriority = 100
+
+type goldmarkConverter struct {
+	md goldmark.Markdown
+}
+
+func newGoldmarkConverter(h *highlighter) Converter {
+	return &goldmarkConverter{
+		md: goldmark.New(
+			goldmark.WithExtensions(
+				extension.GFM,
+				extension.Footnote,
+				extension.DefinitionList,
+				extension.Typographer,
+			),
+			goldmark.WithRendererOptions(
+				html.WithXHTML(),
+				html.WithUnsafe(),
+				renderer.WithNodeRenderers(
+					util.Prioritized(&codeBlockRenderer{highlighter: h}, codeBlockPriority),
+				),
+			),
+		),
+	}
+}
+
+func (c *goldmarkConverter) Convert(source []byte) (*Document, error) {
+	var (
+		node = c.md.Parser().Parse(text.NewReader(source))
+		ids  = make(headingIDs)
+		toc  []*blog.Heading
+	)
+	// the ids are set here rather than by parser.WithAutoHeadingID which drops the non-ASCII characters
+	err := ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		text := goldmarkText(heading, source)
+		id := ids.generate(text)
+		heading.SetAttributeString("id", []byte(id))
+		toc = append(toc, &blog.Heading{
+			Level: heading.Level,
+			Text:  text,
+			Slug:  id,
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build table of contents")
+	}
+
+	var buf bytes.Buffer
+	if err := c.md.Renderer().Render(&buf, source, node); err != nil {
+		return nil, errors.Wrap(err, "failed to convert markdown")
+	}
+
+	return &Document{
+		HTML: template.HTML(buf.String()),
+		TOC:  toc,
+	}, nil
+}
+
+// goldmarkText returns the plain text of the inline nodes, the entities written by the typographer are unescaped
+func goldmarkText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+		case *ast.String:
+			b.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return stdhtml.UnescapeString(b.String())
+}
+
+// codeBlockRenderer renders fenced and indented code blocks with chroma
+type codeBlockRenderer struct {
+	highlighter *highlighter
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+	reg.Register(ast.KindCodeBlock, r.renderCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	var lang string
+	if n, ok := node.(*ast.FencedCodeBlock); ok {
+		lang = string(n.Language(source))
+	}
+
+	var code bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	if err := r.highlighter.highlight(w, lang, code.String()); err != nil {
+		return ast.WalkStop, err
+	}
+
+	return ast.WalkSkipChildren, nil
+}
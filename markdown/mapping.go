@@ -0,0 +1,168 @@
+package markdown
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	unicodetokenizer "github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/registry"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	// mappingVersion must be increased whenever the mapping is changed, so that the existing index is rebuilt
+	mappingVersion    = "2"
+	mappingVersionKey = "mapping_version"
+
+	postType           = "post"
+	foldingTokenFilter = "diacritics_folding"
+	foldingAnalyzer    = "folding"
+)
+
+func init() {
+	registry.RegisterTokenFilter(foldingTokenFilter, func(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+		return &foldingFilter{}, nil
+	})
+}
+
+// foldingFilter removes the diacritics from the tokens, so "du lich" matches "Du lịch"
+type foldingFilter struct{}
+
+func (f *foldingFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = fold(token.Term)
+	}
+
+	return input
+}
+
+// fold decomposes the term, drops the combining marks and replaces đ which has no decomposition
+func fold(term []byte) []byte {
+	t := transform.Chain(
+		norm.NFD,
+		runes.Remove(runes.In(unicode.Mn)),
+		runes.Map(func(r rune) rune {
+			switch r {
+			case 'đ':
+				return 'd'
+			case 'Đ':
+				return 'D'
+			}
+			return r
+		}),
+		norm.NFC,
+	)
+	folded, _, err := transform.Bytes(t, term)
+	if err != nil {
+		return term
+	}
+
+	return folded
+}
+
+// indexedPost is the document which is indexed for a post: the content is indexed as plain text rather than HTML
+type indexedPost struct {
+	Title       string
+	Description string
+	Text        string
+	Tags        []string
+	Categories  []string
+	Year        string
+	Date        time.Time
+	TOC         []*indexedHeading
+}
+
+// indexedHeading is a heading of the table of contents, it does not use blog.Heading
+// because bleve names the fields after their JSON tags
+type indexedHeading struct {
+	Text string
+	Slug string
+}
+
+func newIndexedPost(post *blog.Post) *indexedPost {
+	toc := make([]*indexedHeading, 0, len(post.TOC))
+	for _, heading := range post.TOC {
+		toc = append(toc, &indexedHeading{
+			Text: heading.Text,
+			Slug: heading.Slug,
+		})
+	}
+
+	return &indexedPost{
+		Title:       post.Title,
+		Description: post.Description,
+		Text:        plainText(post.Content),
+		Tags:        post.Tags,
+		Categories:  post.Categories,
+		Year:        post.Date.GetYear(),
+		Date:        post.Date.Time,
+		TOC:         toc,
+	}
+}
+
+// newIndexMapping returns the explicit mapping of indexedPost: the text fields fold the diacritics,
+// tags and categories are also indexed as keywords to be used in the facets and the field queries
+func newIndexMapping() (mapping.IndexMapping, error) {
+	indexMapping := bleve.NewIndexMapping()
+	if err := indexMapping.AddCustomAnalyzer(foldingAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicodetokenizer.Name,
+		"token_filters": []string{lowercase.Name, foldingTokenFilter},
+	}); err != nil {
+		return nil, err
+	}
+	indexMapping.DefaultAnalyzer = foldingAnalyzer
+
+	tocMapping := bleve.NewDocumentMapping()
+	tocMapping.Dynamic = false
+	tocMapping.AddFieldMappingsAt("Text", newTextFieldMapping())
+	slugFieldMapping := bleve.NewTextFieldMapping()
+	slugFieldMapping.Index = false
+	slugFieldMapping.IncludeInAll = false
+	slugFieldMapping.IncludeTermVectors = false
+	tocMapping.AddFieldMappingsAt("Slug", slugFieldMapping)
+
+	dateFieldMapping := bleve.NewDateTimeFieldMapping()
+	dateFieldMapping.IncludeInAll = false
+
+	postMapping := bleve.NewDocumentMapping()
+	postMapping.Dynamic = false
+	postMapping.AddFieldMappingsAt("Title", newTextFieldMapping())
+	postMapping.AddFieldMappingsAt("Description", newTextFieldMapping())
+	postMapping.AddFieldMappingsAt("Text", newTextFieldMapping())
+	postMapping.AddFieldMappingsAt("Tags", newTextFieldMapping(), newKeywordFieldMapping(tagField))
+	postMapping.AddFieldMappingsAt("Categories", newTextFieldMapping(), newKeywordFieldMapping(categoryField))
+	postMapping.AddFieldMappingsAt("Year", newKeywordFieldMapping(yearField))
+	postMapping.AddFieldMappingsAt("Date", dateFieldMapping)
+	postMapping.AddSubDocumentMapping("TOC", tocMapping)
+	// the analyzers of the renamed fields are only looked up in the type mappings
+	indexMapping.AddDocumentMapping(postType, postMapping)
+	indexMapping.DefaultType = postType
+
+	return indexMapping, nil
+}
+
+func newTextFieldMapping() *mapping.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Analyzer = foldingAnalyzer
+	return fieldMapping
+}
+
+func newKeywordFieldMapping(name string) *mapping.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Name = name
+	fieldMapping.Analyzer = keyword.Name
+	fieldMapping.IncludeInAll = false
+	fieldMapping.DocValues = true
+	return fieldMapping
+}
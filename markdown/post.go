@@ -3,8 +3,8 @@ package markdown
 import (
 	"context"
 	"fmt"
-	"html/template"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
@@ -14,12 +14,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Depado/bfchroma"
-	"github.com/alecthomas/chroma/formatters/html"
-	"github.com/alecthomas/chroma/styles"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	bf "github.com/russross/blackfriday/v2"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 
@@ -37,21 +33,22 @@ const (
 	Extension            = ".md"
 )
 
-// GetAllPosts gets all posts in root directory
-func GetAllPosts(root string) ([]*blog.Post, error) {
+// GetAllPosts gets all posts of the content source
+func GetAllPosts(source blog.ContentSource, converter Converter) ([]*blog.Post, error) {
+	fsys := source.FS()
 	g, ctx := errgroup.WithContext(context.Background())
 	paths := make(chan string)
 	g.Go(func() error {
 		defer close(paths)
-		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
-			if filepath.Ext(path) != Extension {
+			if d.IsDir() || path.Ext(name) != Extension {
 				return nil
 			}
 			select {
-			case paths <- path:
+			case paths <- name:
 			case <-ctx.Done():
 				return ctx.Err()
 			}
@@ -64,11 +61,7 @@ func GetAllPosts(root string) ([]*blog.Post, error) {
 	for i := 0; i < maxProcs; i++ {
 		g.Go(func() error {
 			for p := range paths {
-				f, err := os.Open(p)
-				if err != nil {
-					return errors.Wrapf(err, "failed to open file: %s", p)
-				}
-				post, err := Parse(ctx, root, f)
+				post, err := ParseFile(ctx, converter, fsys, p)
 				if err != nil {
 					return errors.Wrapf(err, "failed to parse markdown: %s", p)
 				}
@@ -89,6 +82,9 @@ func GetAllPosts(root string) ([]*blog.Post, error) {
 
 	var posts []*blog.Post
 	for post := range postsCh {
+		if post.Draft {
+			continue
+		}
 		posts = append(posts, post)
 	}
 
@@ -103,49 +99,216 @@ func GetAllPosts(root string) ([]*blog.Post, error) {
 	return posts, nil
 }
 
+// postService serves a version of the content: its own copy of the posts and the indexes built from them.
+// Nothing is modified after NewPostService returns, so it can be read concurrently while a new version is built;
+// the returned posts, slices and maps are shared between the callers so they must not be modified either.
 type postService struct {
-	posts []*blog.Post
+	posts     []*blog.Post
+	unlisted  []*blog.Post
+	scheduled []*blog.Post
+	// latest are the published posts sorted by date, newest first
+	latest []*blog.Post
+
+	byURI            map[string]*blog.Post
+	byTag            map[string][]*blog.Post
+	postsPerTag      map[string]int
+	tags             []string
+	byCategory       map[string][]*blog.Post
+	byTranslationKey map[string][]*blog.Post
+	bySeries         map[string][]*blog.Post
+	years            []string
+	monthsInYear     map[string][]string
+	byYear           map[string][]*blog.Post
+	byMonth          map[string]map[string][]*blog.Post
+	byDate           map[string][]*blog.Post
+	imageAddresses   []string
+	postURIByImage   map[string]string
+
+	related *relatedIndex
 }
 
-// NewPostService returns new post service
-func NewPostService(posts []*blog.Post) blog.PostService {
-	return &postService{
-		posts: posts,
+// NewPostService returns new post service.
+// Posts are split by their state at the current time: drafts are dropped,
+// scheduled posts are hidden until they are published, unlisted posts are only reachable by URI.
+// The posts are copied, the published ones get their position as ID.
+// The recommender can be shared with the previous post service so that only the changed posts are tokenized again,
+// a new one is created if it is nil.
+func NewPostService(posts []*blog.Post, recommender *Recommender) blog.PostService {
+	if recommender == nil {
+		recommender = NewRecommender()
+	}
+
+	var (
+		now = time.Now()
+		ps  = new(postService)
+	)
+	for _, p := range posts {
+		post := new(blog.Post)
+		*post = *p
+		switch {
+		case post.Draft:
+		case !post.IsPublished(now):
+			ps.scheduled = append(ps.scheduled, post)
+		case post.Unlisted:
+			ps.unlisted = append(ps.unlisted, post)
+		default:
+			post.ID = len(ps.posts)
+			ps.posts = append(ps.posts, post)
+		}
+	}
+	ps.buildIndexes()
+	ps.related = recommender.update(ps.posts)
+
+	return ps
+}
+
+// buildIndexes indexes the published posts by URI, taxonomy and date, so that every lookup is a map access
+func (ps *postService) buildIndexes() {
+	ps.byURI = make(map[string]*blog.Post, len(ps.posts)+len(ps.unlisted))
+	for _, post := range ps.unlisted {
+		ps.byURI[post.URI] = post
+	}
+
+	ps.byTag = make(map[string][]*blog.Post)
+	ps.postsPerTag = make(map[string]int)
+	ps.byCategory = make(map[string][]*blog.Post)
+	ps.byTranslationKey = make(map[string][]*blog.Post)
+	ps.bySeries = make(map[string][]*blog.Post)
+	ps.monthsInYear = make(map[string][]string)
+	ps.byYear = make(map[string][]*blog.Post)
+	ps.byMonth = make(map[string]map[string][]*blog.Post)
+	ps.byDate = make(map[string][]*blog.Post)
+	ps.postURIByImage = make(map[string]string)
+	for _, post := range ps.posts {
+		ps.byURI[post.URI] = post
+
+		for _, tag := range post.Tags {
+			if n := len(ps.byTag[tag]); n == 0 || ps.byTag[tag][n-1] != post {
+				ps.byTag[tag] = append(ps.byTag[tag], post)
+			}
+		}
+		for _, category := range post.Categories {
+			ps.byCategory[category] = append(ps.byCategory[category], post)
+		}
+		if post.TranslationKey != "" {
+			ps.byTranslationKey[post.TranslationKey] = append(ps.byTranslationKey[post.TranslationKey], post)
+		}
+		if post.Series != "" {
+			ps.bySeries[post.Series] = append(ps.bySeries[post.Series], post)
+		}
+
+		year, month, day := post.Date.GetYear(), post.Date.GetMonth(), post.Date.GetDay()
+		if _, found := ps.byMonth[year]; !found {
+			ps.years = append(ps.years, year)
+			ps.byMonth[year] = make(map[string][]*blog.Post)
+		}
+		if _, found := ps.byMonth[year][month]; !found {
+			ps.monthsInYear[year] = append(ps.monthsInYear[year], month)
+		}
+		ps.byYear[year] = append(ps.byYear[year], post)
+		ps.byMonth[year][month] = append(ps.byMonth[year][month], post)
+		ps.byDate[year+month+day] = append(ps.byDate[year+month+day], post)
+
+		if blog.Contains(post.Categories, travelCategory) {
+			ps.imageAddresses = append(ps.imageAddresses, post.Images...)
+			for _, image := range post.Images {
+				ps.postURIByImage[image] = post.URI
+			}
+		}
+	}
+
+	// a post is counted once per tag, even if the tag is repeated in its front matter
+	for tag, posts := range ps.byTag {
+		ps.postsPerTag[tag] = len(posts)
+		ps.tags = append(ps.tags, tag)
 	}
+	sort.Slice(ps.tags, func(i, j int) bool {
+		ci, cj := ps.postsPerTag[ps.tags[i]], ps.postsPerTag[ps.tags[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return ps.tags[i] < ps.tags[j]
+	})
+	for _, translations := range ps.byTranslationKey {
+		sort.SliceStable(translations, func(i, j int) bool {
+			return translations[i].Lang < translations[j].Lang
+		})
+	}
+	for _, parts := range ps.bySeries {
+		sortSeries(parts)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ps.years)))
+	for _, months := range ps.monthsInYear {
+		sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	}
+
+	ps.latest = append([]*blog.Post(nil), ps.posts...)
+	sort.SliceStable(ps.latest, func(i, j int) bool {
+		return ps.latest[i].Date.After(ps.latest[j].Date.Time)
+	})
 }
 
 func (ps *postService) GetAllPosts() []*blog.Post {
 	return ps.posts
 }
 
+func (ps *postService) GetUnlistedPosts() []*blog.Post {
+	return ps.unlisted
+}
+
+func (ps *postService) GetScheduledPosts() []*blog.Post {
+	return ps.scheduled
+}
+
 func (ps *postService) GetPostByURI(uri string) *blog.Post {
-	for _, post := range ps.posts {
-		if post.URI == uri {
-			return post
-		}
+	return ps.byURI[uri]
+}
+
+// GetLatestPosts returns the posts published in the last days, found by a binary search in the date index
+func (ps *postService) GetLatestPosts(days int) []*blog.Post {
+	now := time.Now()
+	n := sort.Search(len(ps.latest), func(i int) bool {
+		return !ps.latest[i].Date.AddDate(0, 0, days).After(now)
+	})
+
+	return ps.latest[:n]
+}
+
+// Parse parses markdown file, returns a blog post
+func Parse(ctx context.Context, converter Converter, root string, r io.Reader) (*blog.Post, error) {
+	p, err := parse(ctx, converter, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := r.(type) {
+	case *os.File:
+		p.URI = GetURI(root, v.Name())
+	default:
+		p.URI = "/" + path.Join(p.Date.GetYear(), p.Date.GetMonth(), p.Date.GetDay(), fmt.Sprintf("%s%s", url.QueryEscape(strings.ToLower(p.Title)), Extension))
 	}
 
-	return nil
+	return p, nil
 }
 
-func (ps *postService) GetLatestPosts(days int) []*blog.Post {
-	var (
-		now         = time.Now()
-		latestPosts []*blog.Post
-	)
-	for _, post := range ps.posts {
-		if post.Date.Time.AddDate(0, 0, days).After(now) {
-			latestPosts = append(latestPosts, post)
-		} else {
-			break
-		}
+// ParseFile parses the markdown file name of fsys, the URI of the post is the path of the file
+func ParseFile(ctx context.Context, converter Converter, fsys fs.FS, name string) (*blog.Post, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file: %s", name)
 	}
+	defer f.Close()
 
-	return latestPosts
+	p, err := parse(ctx, converter, f)
+	if err != nil {
+		return nil, err
+	}
+	p.URI = "/" + name
+
+	return p, nil
 }
 
-// Parse parses markdown file, returns a blog post
-func Parse(ctx context.Context, root string, r io.Reader) (*blog.Post, error) {
+func parse(ctx context.Context, converter Converter, r io.Reader) (*blog.Post, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -172,44 +335,26 @@ func Parse(ctx context.Context, root string, r io.Reader) (*blog.Post, error) {
 			log.Printf("failed to decode metadata: %v", err)
 		}
 
-		switch v := r.(type) {
-		case *os.File:
-			name := v.Name()
-			basename := filepath.Base(name)
-			p.URI = path.Join(strings.TrimPrefix(filepath.Dir(name), root), basename)
-		default:
-			p.URI = path.Join(p.Date.GetYear(), p.Date.GetMonth(), p.Date.GetDay(), fmt.Sprintf("%s%s", url.QueryEscape(strings.ToLower(p.Title)), Extension))
-		}
-		if !strings.HasPrefix(p.URI, "/") {
-			p.URI = "/" + p.URI
-		}
-
 		content := strings.Join(lines[closingMetadataLine+1:], newLineSeparator)
 		if len(strings.Split(content, wordSeparator)) > summaryLength {
 			p.Truncated = true
 		} else {
 			p.Truncated = false
 		}
-		options := []html.Option{
-			html.WithLineNumbers(true),
-			html.TabWidth(4),
+		doc, err := converter.Convert([]byte(content))
+		if err != nil {
+			return nil, err
+		}
+		p.Content = doc.HTML
+		p.TOC = doc.TOC
+
+		words := proseWords(p.Content)
+		p.WordCount = len(words)
+		p.ReadingTime = readingTime(p.WordCount)
+		if p.Lang == "" {
+			p.Lang = detectLanguage(append(strings.Fields(p.Title), words...))
 		}
 
-		// remove SmartypantsFractions
-		htmlFlags := bf.UseXHTML | bf.Smartypants | bf.SmartypantsDashes | bf.SmartypantsLatexDashes
-		renderer := bfchroma.NewRenderer(
-			bfchroma.WithoutAutodetect(),
-			bfchroma.ChromaOptions(options...),
-			bfchroma.ChromaStyle(styles.SolarizedDark),
-			bfchroma.Extend(bf.NewHTMLRenderer(bf.HTMLRendererParameters{
-				Flags: htmlFlags,
-			})),
-		)
-		p.Content = template.HTML(bf.Run(
-			[]byte(content),
-			bf.WithRenderer(renderer),
-		))
-
 		var (
 			summaries         []string
 			numThreeBackticks int
@@ -223,53 +368,29 @@ func Parse(ctx context.Context, root string, r io.Reader) (*blog.Post, error) {
 				break
 			}
 		}
-		p.Summary = template.HTML(bf.Run(
-			[]byte(strings.Join(summaries, newLineSeparator)),
-			bf.WithRenderer(renderer),
-		))
+		summary, err := converter.Convert([]byte(strings.Join(summaries, newLineSeparator)))
+		if err != nil {
+			return nil, err
+		}
+		p.Summary = summary.HTML
 
 		return &p, nil
 	}
 }
 
-func (ps *postService) GetRelatedPosts(currentPost *blog.Post) []*blog.Post {
-	var (
-		m            = make(map[int]*blog.Post)
-		relatedPosts []*blog.Post
-	)
-	for _, tag := range currentPost.Tags {
-		for _, post := range ps.posts {
-			if post.ID != currentPost.ID && contains(post.Tags, tag) {
-				_, found := m[post.ID]
-				m[post.ID] = post
-				if !found {
-					relatedPosts = append(relatedPosts, post)
-				}
-			}
-
-			if len(relatedPosts) >= numberOfRelatedPosts {
-				return relatedPosts[:numberOfRelatedPosts]
-			}
-		}
+// GetURI returns the URI of the post stored in the file name, relative to the root directory
+func GetURI(root, name string) string {
+	uri := path.Join(filepath.ToSlash(strings.TrimPrefix(filepath.Dir(name), root)), filepath.Base(name))
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
 	}
 
-	for _, category := range currentPost.Categories {
-		for _, post := range ps.posts {
-			if post.ID != currentPost.ID && contains(post.Categories, category) {
-				_, found := m[post.ID]
-				m[post.ID] = post
-				if !found {
-					relatedPosts = append(relatedPosts, post)
-				}
-			}
-
-			if len(relatedPosts) >= numberOfRelatedPosts {
-				return relatedPosts[:numberOfRelatedPosts]
-			}
-		}
-	}
+	return uri
+}
 
-	return relatedPosts
+// GetRelatedPosts returns the posts which are the most similar to the current post
+func (ps *postService) GetRelatedPosts(currentPost *blog.Post) []*blog.Post {
+	return ps.related.Related(currentPost, numberOfRelatedPosts)
 }
 
 func contains(s []string, str string) bool {
@@ -283,180 +404,131 @@ func contains(s []string, str string) bool {
 }
 
 func (ps *postService) GetAllCategories() map[string][]*blog.Post {
-	categories := make(map[string][]*blog.Post)
-	for _, post := range ps.posts {
-		for _, c := range post.Categories {
-			categories[c] = append(categories[c], post)
-		}
-	}
-
-	return categories
+	return ps.byCategory
 }
 
 func (ps *postService) GetPostsPerTag() map[string]int {
-	m := make(map[string]int)
-	for _, post := range ps.posts {
-		for _, t := range post.Tags {
-			m[t]++
-		}
-	}
-
-	return m
+	return ps.postsPerTag
 }
 
+// GetAllTags returns the tags, the most used first
 func (ps *postService) GetAllTags() []string {
-	m := ps.GetPostsPerTag()
-
-	type kv struct {
-		tag   string
-		count int
-	}
-
-	var tc []kv
-	for t, c := range m {
-		tc = append(tc, kv{
-			tag:   t,
-			count: c,
-		})
-	}
-	sort.Slice(tc, func(i, j int) bool {
-		return tc[i].count > tc[j].count
-	})
-
-	tags := make([]string, 0, len(m))
-	for _, kv := range tc {
-		tags = append(tags, kv.tag)
-	}
-
-	return tags
+	return ps.tags
 }
 
 func (ps *postService) GetImageAddresses() []string {
-	var imageAddresses []string
-	for _, post := range ps.posts {
-		if blog.Contains(post.Categories, travelCategory) {
-			imageAddresses = append(imageAddresses, post.Images...)
-		}
-	}
-	return imageAddresses
+	return ps.imageAddresses
 }
 
 func (ps *postService) GetPostURIByImage() map[string]string {
-	m := make(map[string]string)
-	for _, post := range ps.posts {
-		if blog.Contains(post.Categories, travelCategory) {
-			for _, image := range post.Images {
-				m[image] = post.URI
-			}
-		}
-	}
-	return m
+	return ps.postURIByImage
 }
 
 func (ps *postService) GetPostsByCategory(category string) []*blog.Post {
-	return ps.GetAllCategories()[category]
+	return ps.byCategory[category]
 }
 
 func (ps *postService) GetPostsByTag(tag string) []*blog.Post {
-	var postsByTag []*blog.Post
-	for _, post := range ps.posts {
-		for _, t := range post.Tags {
-			if t == tag {
-				postsByTag = append(postsByTag, post)
+	return ps.byTag[tag]
+}
+
+// GetPreviousAndNextPost returns the neighbours of the current post: the previous and next parts if it belongs to a series,
+// otherwise the posts published before and after it
+func (ps *postService) GetPreviousAndNextPost(currentPost *blog.Post) (previousPost, nextPost *blog.Post) {
+	if currentPost.Unlisted {
+		return nil, nil
+	}
+
+	if currentPost.Series != "" {
+		parts := ps.bySeries[currentPost.Series]
+		for i, part := range parts {
+			if part.URI != currentPost.URI {
+				continue
 			}
+			if i > 0 {
+				previousPost = parts[i-1]
+			}
+			if i < len(parts)-1 {
+				nextPost = parts[i+1]
+			}
+			return previousPost, nextPost
 		}
 	}
 
-	return postsByTag
-}
-
-func (ps *postService) GetPreviousAndNextPost(currentPost *blog.Post) (previousPost, nextPost *blog.Post) {
-	id := currentPost.ID
-	if id < len(ps.posts)-1 {
+	post, found := ps.byURI[currentPost.URI]
+	if !found || post.Unlisted {
+		return nil, nil
+	}
+	if id := post.ID; id < len(ps.posts)-1 {
 		previousPost = ps.posts[id+1]
 	}
-	if id > 0 {
+	if id := post.ID; id > 0 {
 		nextPost = ps.posts[id-1]
 	}
 
 	return previousPost, nextPost
 }
 
-func (ps *postService) GetYears() []string {
-	m := make(map[string]struct{})
-	for _, post := range ps.posts {
-		m[post.Date.GetYear()] = struct{}{}
+// GetTranslations returns the other posts having the same translation key, ordered by language
+func (ps *postService) GetTranslations(currentPost *blog.Post) []*blog.Post {
+	if currentPost.TranslationKey == "" {
+		return nil
 	}
 
-	var years []string
-	for y := range m {
-		years = append(years, y)
+	var translations []*blog.Post
+	for _, post := range ps.byTranslationKey[currentPost.TranslationKey] {
+		if post.URI != currentPost.URI {
+			translations = append(translations, post)
+		}
 	}
-	sort.Slice(years, func(i, j int) bool {
-		return years[i] > years[j]
-	})
-	return years
+
+	return translations
 }
 
-func (ps *postService) GetMonthsInYear() map[string][]string {
-	monthsInYear := make(map[string][]string)
-	for _, year := range ps.GetYears() {
-		var (
-			m      = make(map[string]struct{})
-			months []string
-		)
-		for _, post := range ps.posts {
-			if post.Date.GetYear() == year {
-				month := post.Date.GetMonth()
-				_, found := m[month]
-				m[month] = struct{}{}
-				if !found {
-					months = append(months, month)
-				}
-			}
+// GetAllSeries returns the posts of every series, in reading order
+func (ps *postService) GetAllSeries() map[string][]*blog.Post {
+	return ps.bySeries
+}
+
+// GetPostsBySeries returns the posts of a series, in reading order
+func (ps *postService) GetPostsBySeries(series string) []*blog.Post {
+	return ps.bySeries[series]
+}
+
+// sortSeries sorts the parts of a series by their order, the parts without order come last, by date
+func sortSeries(parts []*blog.Post) {
+	sort.SliceStable(parts, func(i, j int) bool {
+		oi, oj := parts[i].SeriesOrder, parts[j].SeriesOrder
+		switch {
+		case oi > 0 && oj > 0 && oi != oj:
+			return oi < oj
+		case oi > 0 && oj == 0:
+			return true
+		case oi == 0 && oj > 0:
+			return false
 		}
+		return parts[i].Date.Before(parts[j].Date.Time)
+	})
+}
 
-		sort.Slice(months, func(i, j int) bool {
-			return months[i] > months[j]
-		})
-		monthsInYear[year] = months
-	}
+// GetYears returns the years having posts, the latest first
+func (ps *postService) GetYears() []string {
+	return ps.years
+}
 
-	return monthsInYear
+// GetMonthsInYear returns the months having posts by year, the latest first
+func (ps *postService) GetMonthsInYear() map[string][]string {
+	return ps.monthsInYear
 }
 
 func (ps *postService) GetPostsByDate(year, month, day string) []*blog.Post {
-	var postsByDate []*blog.Post
-	for _, post := range ps.posts {
-		if post.Date.GetYear() == year && post.Date.GetMonth() == month && post.Date.GetDay() == day {
-			postsByDate = append(postsByDate, post)
-		}
-	}
-	return postsByDate
+	return ps.byDate[year+month+day]
 }
 
 func (ps *postService) GetPostsByMonth() map[string]map[string][]*blog.Post {
-	ym := make(map[string]map[string][]*blog.Post)
-	for _, year := range ps.GetYears() {
-		mm := make(map[string][]*blog.Post)
-		for _, month := range ps.GetMonthsInYear()[year] {
-			for _, post := range ps.posts {
-				if post.Date.GetYear() == year && post.Date.GetMonth() == month {
-					mm[month] = append(mm[month], post)
-				}
-			}
-		}
-		ym[year] = mm
-	}
-	return ym
+	return ps.byMonth
 }
 
 func (ps *postService) GetPostsByYear(year string) []*blog.Post {
-	var postsByYear []*blog.Post
-	for _, post := range ps.posts {
-		if post.Date.GetYear() == year {
-			postsByYear = append(postsByYear, post)
-		}
-	}
-	return postsByYear
+	return ps.byYear[year]
 }
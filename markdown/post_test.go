@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"fmt"
+	"html/template"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/source"
+)
+
+// newCorpus returns n posts, one per day going back from today, newest first
+func newCorpus(n int) []*blog.Post {
+	now := time.Now()
+	posts := make([]*blog.Post, 0, n)
+	for i := 0; i < n; i++ {
+		p := &blog.Post{
+			URI:        fmt.Sprintf("/post-%d.md", i),
+			Title:      fmt.Sprintf("Post %d", i),
+			Content:    template.HTML(fmt.Sprintf("<p>Post number %d is about topic%d and topic%d.</p>", i, i%50, i%7)),
+			Tags:       []string{fmt.Sprintf("tag%d", i%100), fmt.Sprintf("tag%d", i%3)},
+			Categories: []string{fmt.Sprintf("category%d", i%10)},
+		}
+		p.Date.Time = now.AddDate(0, 0, -i)
+		posts = append(posts, p)
+	}
+
+	return posts
+}
+
+func TestPostServiceIndexes(t *testing.T) {
+	posts := newCorpus(400)
+	posts[1].Tags = append(posts[1].Tags, posts[1].Tags[0])
+	ps := NewPostService(posts, nil)
+
+	assert.Equal(t, 42, ps.GetPostByURI("/post-42.md").ID)
+	assert.Nil(t, ps.GetPostByURI("/unknown.md"))
+	assert.Len(t, ps.GetLatestPosts(10), 10)
+
+	assert.Len(t, ps.GetPostsByTag("tag1"), 135)
+	assert.Equal(t, 135, ps.GetPostsPerTag()["tag1"])
+	assert.Equal(t, []string{"tag0", "tag1", "tag2"}, ps.GetAllTags()[:3])
+	assert.Len(t, ps.GetPostsByCategory("category3"), 40)
+	assert.Len(t, ps.GetAllCategories(), 10)
+
+	year, month, day := posts[0].Date.GetYear(), posts[0].Date.GetMonth(), posts[0].Date.GetDay()
+	assert.Equal(t, year, ps.GetYears()[0])
+	assert.Equal(t, month, ps.GetMonthsInYear()[year][0])
+	assert.Contains(t, ps.GetPostsByMonth()[year][month], posts[0])
+	assert.Equal(t, []*blog.Post{posts[0]}, ps.GetPostsByDate(year, month, day))
+
+	var total int
+	for _, year := range ps.GetYears() {
+		total += len(ps.GetPostsByYear(year))
+		for _, month := range ps.GetMonthsInYear()[year] {
+			require.NotEmpty(t, ps.GetPostsByMonth()[year][month])
+		}
+	}
+	assert.Equal(t, len(posts), total)
+
+	previousPost, nextPost := ps.GetPreviousAndNextPost(posts[1])
+	assert.Equal(t, posts[2].URI, previousPost.URI)
+	assert.Equal(t, posts[0].URI, nextPost.URI)
+
+	// the posts are copied, so the IDs of the posts of another version are not modified
+	assert.Zero(t, posts[42].ID)
+	assert.Equal(t, 1, NewPostService(posts[41:], nil).GetPostByURI("/post-42.md").ID)
+	assert.Equal(t, 42, ps.GetPostByURI("/post-42.md").ID)
+}
+
+func BenchmarkNewPostService(b *testing.B) {
+	posts := newCorpus(10000)
+	recommender := NewRecommender()
+	recommender.update(posts)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewPostService(posts, recommender)
+	}
+}
+
+func BenchmarkPostServiceLookups(b *testing.B) {
+	ps := NewPostService(newCorpus(10000), nil)
+	year := ps.GetYears()[0]
+	month := ps.GetMonthsInYear()[year][0]
+
+	for name, lookup := range map[string]func(){
+		"GetAllCategories": func() { ps.GetAllCategories() },
+		"GetPostsByTag":    func() { ps.GetPostsByTag("tag42") },
+		"GetPostsByMonth":  func() { _ = ps.GetPostsByMonth()[year][month] },
+		"GetMonthsInYear":  func() { _ = ps.GetMonthsInYear()[year] },
+		"GetPostByURI":     func() { ps.GetPostByURI("/post-9999.md") },
+		"GetLatestPosts":   func() { ps.GetLatestPosts(7) },
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				lookup()
+			}
+		})
+	}
+}
+
+func TestGetAllPosts(t *testing.T) {
+	converter, err := NewConverter(&blog.Config{})
+	require.NoError(t, err)
+
+	posts, err := GetAllPosts(source.NewFS(fstest.MapFS{
+		"2021/01/01/old.md":   &fstest.MapFile{Data: []byte("---\ntitle: Old\ndate: 2021-01-01\n---\nOld.")},
+		"2021/06/01/new.md":   &fstest.MapFile{Data: []byte("---\ntitle: New\ndate: 2021-06-01\n---\nNew.")},
+		"2021/07/01/draft.md": &fstest.MapFile{Data: []byte("---\ntitle: Draft\ndate: 2021-07-01\ndraft: true\n---\nDraft.")},
+		"2021/06/01/new.png":  &fstest.MapFile{Data: []byte("png")},
+	}), converter)
+	require.NoError(t, err)
+
+	require.Len(t, posts, 2)
+	assert.Equal(t, "/2021/06/01/new.md", posts[0].URI)
+	assert.Equal(t, "/2021/01/01/old.md", posts[1].URI)
+}
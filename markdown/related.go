@@ -0,0 +1,219 @@
+package markdown
+
+import (
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	// the weights of the text similarity, the shared tags and the shared categories in the score of a related post
+	textWeight     = 0.7
+	tagWeight      = 0.2
+	categoryWeight = 0.1
+	// recencyHalfLife is the number of days between two posts after which the recency factor is halved
+	recencyHalfLife = 365
+	minTermLength   = 3
+)
+
+// Recommender keeps the term frequencies of the posts across the reloads, so only the added and modified posts are tokenized again
+type Recommender struct {
+	mu sync.Mutex
+	// terms are the term frequencies by URI
+	terms map[string]*termFrequencies
+}
+
+type termFrequencies struct {
+	title   string
+	content template.HTML
+	terms   map[string]int
+}
+
+// NewRecommender returns a recommender with an empty cache of term frequencies
+func NewRecommender() *Recommender {
+	return &Recommender{
+		terms: make(map[string]*termFrequencies),
+	}
+}
+
+// relatedIndex scores the related posts of a version of the content by TF-IDF similarity, shared tags,
+// shared categories and recency. Only its cache of the related posts is modified after it is built.
+type relatedIndex struct {
+	posts   []*blog.Post
+	idf     map[string]float64
+	vectors map[string]map[string]float64
+
+	mu      sync.Mutex
+	related map[string][]*blog.Post
+}
+
+// update tokenizes the posts which have changed since the previous update, then computes the TF-IDF vectors
+// of all the posts since the document frequencies have changed
+func (r *Recommender) update(posts []*blog.Post) *relatedIndex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	terms := make(map[string]*termFrequencies, len(posts))
+	df := make(map[string]int)
+	for _, post := range posts {
+		tf, found := r.terms[post.URI]
+		if !found || tf.title != post.Title || tf.content != post.Content {
+			tf = newTermFrequencies(post)
+		}
+		terms[post.URI] = tf
+		for term := range tf.terms {
+			df[term]++
+		}
+	}
+	r.terms = terms
+
+	index := &relatedIndex{
+		posts:   posts,
+		idf:     make(map[string]float64, len(df)),
+		vectors: make(map[string]map[string]float64, len(posts)),
+		related: make(map[string][]*blog.Post),
+	}
+	for term, n := range df {
+		index.idf[term] = math.Log(1 + float64(len(posts))/float64(n))
+	}
+	for uri, tf := range terms {
+		index.vectors[uri] = index.vector(tf)
+	}
+
+	return index
+}
+
+// Related returns the n posts which are the most similar to the current post, its translations are left out
+func (ri *relatedIndex) Related(currentPost *blog.Post, n int) []*blog.Post {
+	ri.mu.Lock()
+	related, found := ri.related[currentPost.URI]
+	ri.mu.Unlock()
+	if found {
+		return related
+	}
+
+	vector, found := ri.vectors[currentPost.URI]
+	if !found {
+		// unlisted posts are not recommended but they have related posts too
+		vector = ri.vector(newTermFrequencies(currentPost))
+	}
+
+	type candidate struct {
+		post  *blog.Post
+		score float64
+	}
+	var candidates []candidate
+	for _, post := range ri.posts {
+		if post.URI == currentPost.URI || (currentPost.TranslationKey != "" && post.TranslationKey == currentPost.TranslationKey) {
+			continue
+		}
+
+		score := textWeight*cosine(vector, ri.vectors[post.URI]) +
+			tagWeight*overlap(currentPost.Tags, post.Tags) +
+			categoryWeight*overlap(currentPost.Categories, post.Categories)
+		if score <= 0 {
+			continue
+		}
+
+		days := math.Abs(currentPost.Date.Sub(post.Date.Time).Hours()) / 24
+		score *= 0.5 + 0.5*math.Pow(0.5, days/recencyHalfLife)
+		candidates = append(candidates, candidate{post: post, score: score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	related = make([]*blog.Post, 0, n)
+	for i := 0; i < len(candidates) && i < n; i++ {
+		related = append(related, candidates[i].post)
+	}
+	if found {
+		ri.mu.Lock()
+		ri.related[currentPost.URI] = related
+		ri.mu.Unlock()
+	}
+
+	return related
+}
+
+// vector returns the normalized TF-IDF vector of the term frequencies
+func (ri *relatedIndex) vector(tf *termFrequencies) map[string]float64 {
+	var (
+		vector = make(map[string]float64, len(tf.terms))
+		norm   float64
+	)
+	for term, count := range tf.terms {
+		weight := float64(count) * ri.idf[term]
+		vector[term] = weight
+		norm += weight * weight
+	}
+	if norm == 0 {
+		return vector
+	}
+
+	norm = math.Sqrt(norm)
+	for term := range vector {
+		vector[term] /= norm
+	}
+
+	return vector
+}
+
+// newTermFrequencies counts the folded terms of the title and the prose of a post, the code blocks are left out
+func newTermFrequencies(post *blog.Post) *termFrequencies {
+	tf := &termFrequencies{
+		title:   post.Title,
+		content: post.Content,
+		terms:   make(map[string]int),
+	}
+	words := append(strings.Fields(post.Title), proseWords(post.Content)...)
+	for _, word := range words {
+		for _, term := range strings.FieldsFunc(strings.ToLower(word), isNotLetterOrDigit) {
+			if len([]rune(term)) < minTermLength {
+				continue
+			}
+			tf.terms[string(fold([]byte(term)))]++
+		}
+	}
+
+	return tf
+}
+
+func isNotLetterOrDigit(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// cosine returns the cosine similarity of two normalized vectors
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	var dot float64
+	for term, weight := range a {
+		dot += weight * b[term]
+	}
+
+	return dot
+}
+
+// overlap returns the Jaccard index of two sets of terms
+func overlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	var shared int
+	for _, s := range a {
+		if contains(b, s) {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
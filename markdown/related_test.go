@@ -0,0 +1,65 @@
+package markdown
+
+import (
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func newTestPost(uri, title, content string, date time.Time, tags ...string) *blog.Post {
+	p := &blog.Post{
+		URI:     uri,
+		Title:   title,
+		Content: template.HTML("<p>" + content + "</p>"),
+		Tags:    tags,
+	}
+	p.Date.Time = date
+
+	return p
+}
+
+func TestRecommender(t *testing.T) {
+	var (
+		now       = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		current   = newTestPost("/current.md", "Tuning Postgres indexes", "Postgres uses btree indexes, a vacuum keeps the indexes small.", now, "database")
+		similar   = newTestPost("/similar.md", "Postgres vacuum", "Autovacuum cleans the dead tuples of Postgres tables and indexes.", now.AddDate(0, -6, 0), "ops")
+		newest    = newTestPost("/newest.md", "A trip to Hue", "Travelling by train along the coast.", now.AddDate(0, 1, 0), "database")
+		unrelated = newTestPost("/unrelated.md", "Baking bread", "Flour, water and salt.", now, "food")
+	)
+
+	r := NewRecommender()
+	index := r.update([]*blog.Post{newest, current, similar, unrelated})
+
+	related := index.Related(current, 5)
+	require.Equal(t, 2, len(related))
+	assert.Equal(t, "/similar.md", related[0].URI)
+	assert.Equal(t, "/newest.md", related[1].URI)
+
+	// the modified post is tokenized again, the other posts keep their term frequencies
+	modified := newTestPost("/newest.md", "Postgres indexes and vacuum", "Postgres btree indexes need a vacuum.", newest.Date.Time, "database")
+	tf, newestTF := r.terms[similar.URI], r.terms[newest.URI]
+	updated := r.update([]*blog.Post{modified, current, similar, unrelated})
+	assert.Same(t, tf, r.terms[similar.URI])
+	assert.NotSame(t, newestTF, r.terms[modified.URI])
+	assert.Equal(t, "/newest.md", updated.Related(current, 5)[0].URI)
+	// the previous version of the content is not affected by the update
+	assert.Equal(t, related, index.Related(current, 5))
+}
+
+func TestRecommenderSkipsTranslations(t *testing.T) {
+	var (
+		now        = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		english    = newTestPost("/en.md", "Postgres indexes", "Postgres btree indexes.", now)
+		vietnamese = newTestPost("/vi.md", "Postgres indexes", "Postgres btree indexes.", now)
+	)
+	english.TranslationKey = "indexes"
+	vietnamese.TranslationKey = "indexes"
+
+	index := NewRecommender().update([]*blog.Post{english, vietnamese})
+	assert.Empty(t, index.Related(english, 5))
+}
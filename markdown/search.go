@@ -1,49 +1,65 @@
 package markdown
 
 import (
-	"bytes"
-	"encoding/gob"
-	"fmt"
+	"html/template"
 	"os"
+	"strings"
 
 	"github.com/blevesearch/bleve"
-	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/index/scorch"
+	"github.com/blevesearch/bleve/search"
+	htmlhighlighter "github.com/blevesearch/bleve/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/search/query"
 	"github.com/pkg/errors"
+	"golang.org/x/net/html"
 
 	"github.com/quantonganh/blog"
 )
 
+const (
+	// tocTextField is the field of the heading texts, the array position of a match is the index of the heading
+	tocTextField = "TOC.Text"
+	tocSlugField = "TOC.Slug"
+	titleField   = "Title"
+	tagsField    = "Tags"
+	// textField is the content without the HTML tags
+	textField     = "Text"
+	tagField      = "tag"
+	categoryField = "category"
+	yearField     = "year"
+
+	tagsFacet          = "tags"
+	categoriesFacet    = "categories"
+	yearsFacet         = "years"
+	numberOfFacetTerms = 10
+	// numberOfSuggestedTerms is the number of tags of the matching posts to be filtered by the prefix
+	numberOfSuggestedTerms = 100
+
+	titleBoost = 3
+	tagsBoost  = 2
+)
+
 type searchService struct {
 	index bleve.Index
 }
 
 // NewSearchService returns new search service
 func NewSearchService(indexPath string, posts []*blog.Post) (blog.SearchService, error) {
-	var index bleve.Index
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		index, err = bleve.NewUsing(indexPath, bleve.NewIndexMapping(), scorch.Name, scorch.Name, nil)
-		if err != nil {
-			return nil, errors.Errorf("failed to create index at %s: %v", indexPath, err)
-		}
-	} else if err == nil {
-		index, err = bleve.OpenUsing(indexPath, nil)
-		if err != nil {
-			return nil, errors.Errorf("failed to open index at %s: %v", indexPath, err)
-		}
+	index, err := openIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := deletePostsFromIndex(index, posts); err != nil {
-			return nil, err
-		}
+	if err := deletePostsFromIndex(index, posts); err != nil {
+		return nil, err
 	}
 
 	ss := &searchService{
 		index: index,
 	}
 	batch := index.NewBatch()
-	for i, post := range posts {
-		post.ID = i
-
+	for _, post := range posts {
 		if err := ss.Index(post, batch); err != nil {
 			return nil, err
 		}
@@ -56,31 +72,76 @@ func NewSearchService(indexPath string, posts []*blog.Post) (blog.SearchService,
 	return ss, nil
 }
 
-func (ss *searchService) GetIndex() bleve.Index {
-	return ss.index
-}
+// openIndex opens the index at indexPath, it is created again if it was built with an older mapping
+func openIndex(indexPath string) (bleve.Index, error) {
+	if _, err := os.Stat(indexPath); err == nil {
+		index, err := bleve.OpenUsing(indexPath, nil)
+		if err != nil {
+			return nil, errors.Errorf("failed to open index at %s: %v", indexPath, err)
+		}
 
-func (ss *searchService) Index(post *blog.Post, batch *bleve.Batch) error {
-	doc := document.Document{
-		ID: post.URI,
-	}
-	if err := bleve.NewIndexMapping().MapDocument(&doc, post); err != nil {
-		return errors.Errorf("failed to map document: %v", err)
+		version, err := index.GetInternal([]byte(mappingVersionKey))
+		if err != nil {
+			return nil, errors.Errorf("failed to get mapping version of index at %s: %v", indexPath, err)
+		}
+		if string(version) == mappingVersion {
+			return index, nil
+		}
+
+		if err := index.Close(); err != nil {
+			return nil, errors.Errorf("failed to close index at %s: %v", indexPath, err)
+		}
+		if err := os.RemoveAll(indexPath); err != nil {
+			return nil, errors.Errorf("failed to remove index at %s: %v", indexPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to stat %s", indexPath)
 	}
 
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	if err := enc.Encode(post); err != nil {
-		return errors.Errorf("failed to encode post: %v", err)
+	indexMapping, err := newIndexMapping()
+	if err != nil {
+		return nil, errors.Errorf("failed to create index mapping: %v", err)
+	}
+	index, err := bleve.NewUsing(indexPath, indexMapping, scorch.Name, scorch.Name, nil)
+	if err != nil {
+		return nil, errors.Errorf("failed to create index at %s: %v", indexPath, err)
+	}
+	if err := index.SetInternal([]byte(mappingVersionKey), []byte(mappingVersion)); err != nil {
+		return nil, errors.Errorf("failed to set mapping version of index at %s: %v", indexPath, err)
 	}
 
-	field := document.NewTextFieldWithIndexingOptions("_source", nil, b.Bytes(), document.StoreField)
-	if err := batch.IndexAdvanced(doc.AddField(field)); err != nil {
+	return index, nil
+}
+
+func (ss *searchService) GetIndex() bleve.Index {
+	return ss.index
+}
+
+func (ss *searchService) Index(post *blog.Post, batch *bleve.Batch) error {
+	if err := batch.Index(post.URI, newIndexedPost(post)); err != nil {
 		return errors.Errorf("failed to add index to the batch: %v", err)
 	}
 	return nil
 }
 
+// plainText returns the text of the HTML content without the tags, which is used to highlight the fragments
+func plainText(content template.HTML) string {
+	var (
+		b         strings.Builder
+		tokenizer = html.NewTokenizer(strings.NewReader(string(content)))
+	)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case html.TextToken:
+			b.Write(tokenizer.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			b.WriteByte(' ')
+		}
+	}
+}
+
 func deletePostsFromIndex(index bleve.Index, posts []*blog.Post) error {
 	count, err := index.DocCount()
 	if err != nil {
@@ -115,34 +176,167 @@ func isContains(posts []*blog.Post, uri string) bool {
 	return false
 }
 
-func (ss *searchService) Search(value string) ([]*blog.Post, error) {
-	query := bleve.NewMatchQuery(value)
-	request := bleve.NewSearchRequest(query)
-	request.Fields = []string{"_source"}
+func (ss *searchService) Search(req *blog.SearchRequest) (*blog.SearchResult, error) {
+	queryString := bleve.NewQueryStringQuery(req.Query)
+	if _, err := queryString.Parse(); err != nil {
+		return nil, errors.Wrap(blog.ErrInvalidQuery, err.Error())
+	}
 
-	size, err := ss.index.DocCount()
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to count number of documents in the index")
+	// the posts matching the query in the title or the tags are ranked higher
+	title := bleve.NewMatchQuery(req.Query)
+	title.SetField(titleField)
+	title.SetBoost(titleBoost)
+	tags := bleve.NewMatchQuery(req.Query)
+	tags.SetField(tagsField)
+	tags.SetBoost(tagsBoost)
+	query := bleve.NewBooleanQuery()
+	query.AddMust(queryString)
+	query.AddShould(title, tags)
+
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	request := bleve.NewSearchRequestOptions(query, req.Size, (page-1)*req.Size, false)
+	request.Fields = []string{tocSlugField}
+	request.IncludeLocations = true
+	request.Highlight = bleve.NewHighlightWithStyle(htmlhighlighter.Name)
+	request.Highlight.Fields = []string{textField}
+	request.AddFacet(tagsFacet, bleve.NewFacetRequest(tagField, numberOfFacetTerms))
+	request.AddFacet(categoriesFacet, bleve.NewFacetRequest(categoryField, numberOfFacetTerms))
+	request.AddFacet(yearsFacet, bleve.NewFacetRequest(yearField, numberOfFacetTerms))
+	if req.Sort == blog.SortByDate {
+		request.SortBy([]string{"-Date", "-_score"})
 	}
-	request.Size = int(size)
 
 	searchResults, err := ss.index.Search(request)
 	if err != nil {
 		return nil, errors.Errorf("failed to execute a search request: %v", err)
 	}
 
-	var searchPosts []*blog.Post
-	for _, result := range searchResults.Hits {
-		var post *blog.Post
-		b := bytes.NewBuffer([]byte(fmt.Sprintf("%v", result.Fields["_source"])))
-		dec := gob.NewDecoder(b)
-		if err = dec.Decode(&post); err != nil {
-			return nil, errors.Errorf("failed to decode post: %v", err)
+	result := &blog.SearchResult{
+		Total:  int(searchResults.Total),
+		Facets: make(map[string][]*blog.SearchFacet),
+	}
+	for _, hit := range searchResults.Hits {
+		var fragments []template.HTML
+		for _, fragment := range hit.Fragments[textField] {
+			fragments = append(fragments, template.HTML(fragment))
+		}
+		result.Hits = append(result.Hits, &blog.SearchHit{
+			URI:       hit.ID,
+			Score:     hit.Score,
+			Fragments: fragments,
+			Section:   matchedSection(hit),
+		})
+	}
+	for name, facet := range searchResults.Facets {
+		for _, term := range facet.Terms {
+			result.Facets[name] = append(result.Facets[name], &blog.SearchFacet{
+				Term:  term.Term,
+				Count: term.Count,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// Suggest completes the last word of prefix, the other words must match the title or the tags exactly
+func (ss *searchService) Suggest(prefix string, size int) (*blog.Suggestions, error) {
+	tokens := ss.index.Mapping().AnalyzerNamed(foldingAnalyzer).Analyze([]byte(prefix))
+	if len(tokens) == 0 {
+		return &blog.Suggestions{}, nil
+	}
+
+	suggestions := new(blog.Suggestions)
+
+	titles := bleve.NewSearchRequestOptions(prefixQuery(tokens, titleField), size, 0, false)
+	titleResults, err := ss.index.Search(titles)
+	if err != nil {
+		return nil, errors.Errorf("failed to search titles by prefix %s: %v", prefix, err)
+	}
+	for _, hit := range titleResults.Hits {
+		suggestions.URIs = append(suggestions.URIs, hit.ID)
+	}
+
+	// the tags of the matching posts are collected by the facet, then filtered by the prefix
+	tags := bleve.NewSearchRequestOptions(prefixQuery(tokens, tagsField), 0, 0, false)
+	tags.AddFacet(tagsFacet, bleve.NewFacetRequest(tagField, numberOfSuggestedTerms))
+	tagResults, err := ss.index.Search(tags)
+	if err != nil {
+		return nil, errors.Errorf("failed to search tags by prefix %s: %v", prefix, err)
+	}
+	folded := joinTerms(tokens)
+	for _, term := range tagResults.Facets[tagsFacet].Terms {
+		tag := joinTerms(ss.index.Mapping().AnalyzerNamed(foldingAnalyzer).Analyze([]byte(term.Term)))
+		if strings.HasPrefix(tag, folded) || strings.Contains(tag, wordSeparator+folded) {
+			suggestions.Tags = append(suggestions.Tags, term.Term)
 		}
-		searchPosts = append(searchPosts, post)
+		if len(suggestions.Tags) == size {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
+
+// prefixQuery matches the documents having all the analyzed tokens in the field, the last one is a prefix
+func prefixQuery(tokens analysis.TokenStream, field string) query.Query {
+	conjuncts := make([]query.Query, 0, len(tokens))
+	for i, token := range tokens {
+		if i == len(tokens)-1 {
+			q := bleve.NewPrefixQuery(string(token.Term))
+			q.SetField(field)
+			conjuncts = append(conjuncts, q)
+			break
+		}
+		q := bleve.NewTermQuery(string(token.Term))
+		q.SetField(field)
+		conjuncts = append(conjuncts, q)
+	}
+
+	return bleve.NewConjunctionQuery(conjuncts...)
+}
+
+func joinTerms(tokens analysis.TokenStream) string {
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, string(token.Term))
+	}
+
+	return strings.Join(terms, wordSeparator)
+}
+
+// matchedSection returns the slug of the first heading which matches the query
+func matchedSection(hit *search.DocumentMatch) string {
+	var slugs []string
+	switch v := hit.Fields[tocSlugField].(type) {
+	case string:
+		slugs = []string{v}
+	case []interface{}:
+		for _, slug := range v {
+			s, _ := slug.(string)
+			slugs = append(slugs, s)
+		}
+	}
+
+	section := -1
+	for _, locs := range hit.Locations[tocTextField] {
+		for _, loc := range locs {
+			if len(loc.ArrayPositions) == 0 || int(loc.ArrayPositions[0]) >= len(slugs) {
+				continue
+			}
+			if i := int(loc.ArrayPositions[0]); section == -1 || i < section {
+				section = i
+			}
+		}
+	}
+	if section == -1 {
+		return ""
 	}
 
-	return searchPosts, nil
+	return slugs[section]
 }
 
 func (ss *searchService) CloseIndex() error {
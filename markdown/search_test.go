@@ -0,0 +1,146 @@
+package markdown
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func newTestSearchService(t *testing.T) blog.SearchService {
+	var (
+		install = &blog.Post{
+			URI:        "/2021/01/01/install.md",
+			Title:      "Setting up",
+			Content:    "<h2>Prerequisites</h2><p>Go.</p><h2>Installation</h2><p>Run <code>go install</code> to build the blog.</p>",
+			Tags:       []string{"go"},
+			Categories: []string{"Programming"},
+			TOC: []*blog.Heading{
+				{Level: 2, Text: "Prerequisites", Slug: "prerequisites"},
+				{Level: 2, Text: "Installation", Slug: "installation"},
+			},
+		}
+		trip = &blog.Post{
+			URI:        "/2020/05/01/trip.md",
+			Title:      "A trip to build a blog",
+			Content:    "<p>Travelling while building the blog.</p>",
+			Tags:       []string{"travel", "go"},
+			Categories: []string{"Du lịch"},
+		}
+	)
+	install.Date.Time = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	trip.Date.Time = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	ss, err := NewSearchService(filepath.Join(t.TempDir(), "test.bleve"), []*blog.Post{install, trip})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ss.CloseIndex()
+	})
+
+	return ss
+}
+
+func TestSearch(t *testing.T) {
+	ss := newTestSearchService(t)
+
+	result, err := ss.Search(&blog.SearchRequest{Query: "installation", Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Total)
+	assert.Equal(t, "/2021/01/01/install.md", result.Hits[0].URI)
+	assert.Equal(t, "installation", result.Hits[0].Section)
+	assert.Contains(t, result.Hits[0].Fragments[0], "<mark>Installation</mark>")
+
+	result, err = ss.Search(&blog.SearchRequest{Query: "blog", Sort: blog.SortByDate, Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Total)
+	assert.Equal(t, "/2021/01/01/install.md", result.Hits[0].URI)
+	assert.Empty(t, result.Hits[0].Section)
+	assert.Equal(t, []*blog.SearchFacet{{Term: "go", Count: 2}, {Term: "travel", Count: 1}}, result.Facets["tags"])
+	assert.ElementsMatch(t, []*blog.SearchFacet{{Term: "2020", Count: 1}, {Term: "2021", Count: 1}}, result.Facets["years"])
+
+	result, err = ss.Search(&blog.SearchRequest{Query: "blog", Sort: blog.SortByDate, Page: 2, Size: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Total)
+	require.Equal(t, 1, len(result.Hits))
+	assert.Equal(t, "/2020/05/01/trip.md", result.Hits[0].URI)
+
+	result, err = ss.Search(&blog.SearchRequest{Query: `blog +category:"Du lịch"`, Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Total)
+	assert.Equal(t, "/2020/05/01/trip.md", result.Hits[0].URI)
+
+	result, err = ss.Search(&blog.SearchRequest{Query: `"go install"`, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+
+	result, err = ss.Search(&blog.SearchRequest{Query: "Title:trop~1", Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+
+	_, err = ss.Search(&blog.SearchRequest{Query: `"blog`, Size: 10})
+	assert.Equal(t, blog.ErrInvalidQuery, errors.Cause(err))
+}
+
+func TestSearchFoldsDiacritics(t *testing.T) {
+	ss := newTestSearchService(t)
+
+	for _, query := range []string{"du lich", "Du lịch", "DU LICH"} {
+		result, err := ss.Search(&blog.SearchRequest{Query: query, Size: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Total, query)
+		assert.Equal(t, "/2020/05/01/trip.md", result.Hits[0].URI)
+	}
+
+	assert.Equal(t, "Da Nang di duong", string(fold([]byte("Đà Nẵng đi đường"))))
+}
+
+func TestSearchBoostsTitle(t *testing.T) {
+	ss := newTestSearchService(t)
+
+	// "build" is in the content of both posts, but only in the title of the trip
+	result, err := ss.Search(&blog.SearchRequest{Query: "build", Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Total)
+	assert.Equal(t, "/2020/05/01/trip.md", result.Hits[0].URI)
+}
+
+func TestOpenIndexRebuildsOutdatedMapping(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "test.bleve")
+	index, err := bleve.New(indexPath, bleve.NewIndexMapping())
+	require.NoError(t, err)
+	require.NoError(t, index.Close())
+
+	index, err = openIndex(indexPath)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = index.Close()
+	})
+	version, err := index.GetInternal([]byte(mappingVersionKey))
+	require.NoError(t, err)
+	assert.Equal(t, mappingVersion, string(version))
+	assert.Equal(t, foldingAnalyzer, index.Mapping().AnalyzerNameForPath("Title"))
+}
+
+func TestSuggest(t *testing.T) {
+	ss := newTestSearchService(t)
+
+	suggestions, err := ss.Suggest("tr", 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/2020/05/01/trip.md"}, suggestions.URIs)
+	assert.Equal(t, []string{"travel"}, suggestions.Tags)
+
+	suggestions, err = ss.Suggest("a trip to b", 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/2020/05/01/trip.md"}, suggestions.URIs)
+	assert.Empty(t, suggestions.Tags)
+
+	suggestions, err = ss.Suggest("", 5)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions.URIs)
+}
@@ -0,0 +1,48 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const defaultHeadingID = "heading"
+
+// headingIDs generates the heading ids which are unique within a document
+type headingIDs map[string]bool
+
+// generate returns the slug of the heading text, a number is appended if the slug is already used
+func (ids headingIDs) generate(text string) string {
+	slug := slugify(text)
+	if slug == "" {
+		slug = defaultHeadingID
+	}
+
+	id := slug
+	for i := 1; ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", slug, i)
+	}
+	ids[id] = true
+
+	return id
+}
+
+// slugify lowercases the text and replaces each run of characters that are not letters or digits with a hyphen,
+// diacritics are kept so that the anchors of Vietnamese headings are still readable
+func slugify(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		} else {
+			hyphen = true
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package markdown
+
+import (
+	"html/template"
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// Vietnamese is the language code of the posts written in Vietnamese
+	Vietnamese = "vi"
+	// English is the language code of the posts written in English, which is the default
+	English = "en"
+
+	wordsPerMinute = 200
+	// vietnameseRatio is the minimum ratio of the words having Vietnamese letters for a post to be detected as Vietnamese
+	vietnameseRatio   = 0.2
+	vietnameseLetters = "ăâđêôơưàảãáạằẳẵắặầẩẫấậèẻẽéẹềểễếệìỉĩíịòỏõóọồổỗốộờởỡớợùủũúụừửữứựỳỷỹýỵ"
+)
+
+// proseWords returns the words of the HTML content, the code blocks are excluded
+func proseWords(content template.HTML) []string {
+	var (
+		b         strings.Builder
+		depth     int
+		tokenizer = html.NewTokenizer(strings.NewReader(string(content)))
+	)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Fields(b.String())
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "pre" {
+				depth++
+			}
+			b.WriteByte(' ')
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "pre" && depth > 0 {
+				depth--
+			}
+			b.WriteByte(' ')
+		case html.TextToken:
+			if depth == 0 {
+				b.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// readingTime returns the estimated reading time in minutes, rounded up
+func readingTime(wordCount int) int {
+	return int(math.Ceil(float64(wordCount) / wordsPerMinute))
+}
+
+// detectLanguage tells Vietnamese from English by the ratio of the words having Vietnamese letters
+func detectLanguage(words []string) string {
+	if len(words) == 0 {
+		return English
+	}
+
+	var n int
+	for _, word := range words {
+		if strings.ContainsAny(strings.Map(unicode.ToLower, word), vietnameseLetters) {
+			n++
+		}
+	}
+	if float64(n)/float64(len(words)) >= vietnameseRatio {
+		return Vietnamese
+	}
+
+	return English
+}
@@ -0,0 +1,30 @@
+package markdown
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProseWords(t *testing.T) {
+	content := `<p>Run <code>go install</code> first.</p>
+<pre><code>go install github.com/quantonganh/blog
+</code></pre>
+<p>Then start it.</p>`
+	assert.Equal(t, []string{"Run", "go", "install", "first.", "Then", "start", "it."}, proseWords(template.HTML(content)))
+}
+
+func TestReadingTime(t *testing.T) {
+	assert.Equal(t, 0, readingTime(0))
+	assert.Equal(t, 1, readingTime(1))
+	assert.Equal(t, 1, readingTime(200))
+	assert.Equal(t, 2, readingTime(201))
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert.Equal(t, Vietnamese, detectLanguage(strings.Fields("Hôm nay tôi đi du lịch Đà Nẵng cùng gia đình")))
+	assert.Equal(t, English, detectLanguage(strings.Fields("Today I travelled to Da Nang with my family")))
+	assert.Equal(t, English, detectLanguage(nil))
+}
@@ -3,10 +3,49 @@ package blog
 import (
 	"io"
 	"net/http"
+	"time"
 )
 
+// NewsletterService is the interface that wraps the subscription methods, the responses follow the API of the newsletter service:
+// Subscribe answers 200 when a confirmation email is sent, 401 when the subscription is pending and 409 when it is already active,
+// Confirm and Unsubscribe answer 200 or a 4xx with a JSON message, Confirm answers 410 when the token has expired.
+// The body of Subscribe has the url and email fields, and resend to send a new confirmation email to a pending subscriber
 type NewsletterService interface {
 	Subscribe(r *http.Request, body io.Reader) (*http.Response, error)
 	Confirm(r *http.Request, token string) (*http.Response, error)
 	Unsubscribe(r *http.Request, email, hash string) (*http.Response, error)
 }
+
+// Newsletter stores
+const (
+	NewsletterStoreHTTP   = "http"
+	NewsletterStoreSQLite = "sqlite"
+)
+
+// Subscriber statuses
+const (
+	SubscriberPending      = "pending"
+	SubscriberActive       = "active"
+	SubscriberUnsubscribed = "unsubscribed"
+	SubscriberBounced      = "bounced"
+)
+
+// Subscriber represents a subscriber of the newsletter
+type Subscriber struct {
+	Email          string
+	Status         string
+	Token          string
+	TokenExpiresAt time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ConfirmedAt    *time.Time
+	UnsubscribedAt *time.Time
+	BouncedAt      *time.Time
+}
+
+// SubscriberService manages the subscribers stored by the blog
+type SubscriberService interface {
+	FindSubscriber(email string) (*Subscriber, error)
+	ActiveSubscribers() ([]*Subscriber, error)
+	MarkBounced(email string) error
+}
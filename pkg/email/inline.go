@@ -0,0 +1,75 @@
+package email
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+const inlineStyleAttr = "data-inline-style"
+
+var (
+	cssCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssRuleRegexp    = regexp.MustCompile(`(?s)([^{}]+)\{([^{}]*)\}`)
+)
+
+// InlineCSS moves the rules of the style elements into the style attributes of the matching elements,
+// since most mail clients ignore the style elements. The rules are applied in order and the existing
+// style attributes take precedence over them. Only the selectors supported by goquery are applied.
+func InlineCSS(html []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse HTML")
+	}
+
+	var css strings.Builder
+	doc.Find("style").Each(func(_ int, s *goquery.Selection) {
+		css.WriteString(s.Text())
+		s.Remove()
+	})
+
+	doc.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		s.SetAttr(inlineStyleAttr, style)
+		s.RemoveAttr("style")
+	})
+
+	for _, rule := range cssRuleRegexp.FindAllStringSubmatch(cssCommentRegexp.ReplaceAllString(css.String(), ""), -1) {
+		declarations := strings.TrimSuffix(strings.TrimSpace(rule[2]), ";")
+		if declarations == "" {
+			continue
+		}
+		for _, selector := range strings.Split(rule[1], ",") {
+			selector = strings.TrimSpace(selector)
+			if selector == "" || strings.Contains(selector, ":") {
+				continue
+			}
+			doc.Find(selector).Each(func(_ int, s *goquery.Selection) {
+				appendStyle(s, declarations)
+			})
+		}
+	}
+
+	doc.Find("[" + inlineStyleAttr + "]").Each(func(_ int, s *goquery.Selection) {
+		style, _ := s.Attr(inlineStyleAttr)
+		appendStyle(s, strings.TrimSuffix(strings.TrimSpace(style), ";"))
+		s.RemoveAttr(inlineStyleAttr)
+	})
+
+	result, err := doc.Html()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(result), nil
+}
+
+func appendStyle(s *goquery.Selection, declarations string) {
+	if style, ok := s.Attr("style"); ok && style != "" {
+		declarations = style + "; " + declarations
+	}
+	s.SetAttr("style", declarations)
+}
@@ -0,0 +1,35 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineCSS(t *testing.T) {
+	html, err := InlineCSS([]byte(`<html><head><style>
+/* links */
+a, p.note { color: red; }
+a:hover { color: blue; }
+.note { font-size: 12px }
+</style></head><body>
+<a href="/">Home</a>
+<p class="note" style="color: green">Note</p>
+<p>Text</p>
+</body></html>`))
+	require.NoError(t, err)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(html)))
+	require.NoError(t, err)
+	assert.Zero(t, doc.Find("style").Length())
+	style, _ := doc.Find("a").Attr("style")
+	assert.Equal(t, "color: red", style)
+	style, _ = doc.Find("p.note").Attr("style")
+	assert.Equal(t, "color: red; font-size: 12px; color: green", style)
+	_, found := doc.Find("p").Last().Attr("style")
+	assert.False(t, found)
+	assert.Zero(t, doc.Find("["+inlineStyleAttr+"]").Length())
+}
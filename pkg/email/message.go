@@ -0,0 +1,110 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/pkg/errors"
+)
+
+// Message represents an email with an HTML body and an optional plain text alternative
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	// Header is the additional headers, e.g. List-Unsubscribe
+	Header map[string]string
+	HTML   []byte
+	Text   []byte
+}
+
+// Bytes returns the message with its headers, the bodies are quoted-printable encoded.
+// A message with a plain text alternative is a multipart/alternative message whose HTML part is the preferred one.
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid sender %s", m.From)
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	writeHeader("From", from.String())
+	writeHeader("To", m.To)
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", fmt.Sprintf("<%s@%s>", id, domain))
+	keys := make([]string, 0, len(m.Header))
+	for key := range m.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeHeader(key, m.Header[key])
+	}
+	writeHeader("MIME-Version", "1.0")
+
+	if len(m.Text) == 0 {
+		writeHeader("Content-Type", `text/html; charset="utf-8"`)
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.HTML); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	writeHeader("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, w.Boundary()))
+	buf.WriteString("\r\n")
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{`text/plain; charset="utf-8"`, m.Text},
+		{`text/html; charset="utf-8"`, m.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, content []byte) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write(content); err != nil {
+		return err
+	}
+
+	return qw.Close()
+}
@@ -17,6 +17,8 @@ const (
 // PostService is the interface that wraps method related to a blog post
 type PostService interface {
 	GetAllPosts() []*Post
+	GetUnlistedPosts() []*Post
+	GetScheduledPosts() []*Post
 	GetPostByURI(uri string) *Post
 	GetLatestPosts(days int) []*Post
 	GetRelatedPosts(currentPost *Post) []*Post
@@ -28,6 +30,9 @@ type PostService interface {
 	GetPostsByCategory(category string) []*Post
 	GetPostsByTag(tag string) []*Post
 	GetPreviousAndNextPost(currentPost *Post) (previousPost, nextPost *Post)
+	GetTranslations(currentPost *Post) []*Post
+	GetAllSeries() map[string][]*Post
+	GetPostsBySeries(series string) []*Post
 	GetYears() []string
 	GetMonthsInYear() map[string][]string
 	GetPostsByDate(year, month, date string) []*Post
@@ -39,25 +44,68 @@ type PostService interface {
 type SearchService interface {
 	GetIndex() bleve.Index
 	Index(*Post, *bleve.Batch) error
-	Search(value string) ([]*Post, error)
+	Search(req *SearchRequest) (*SearchResult, error)
+	Suggest(prefix string, size int) (*Suggestions, error)
 	CloseIndex() error
 }
 
 // Post represents a blog post
 type Post struct {
-	ID          int
-	URI         string
-	Title       string
-	Date        publishDate
-	Description string
-	Images      []string
-	Content     template.HTML
-	Summary     template.HTML
-	Truncated   bool
-	Categories  []string
-	Tags        []string
-	HasPrev     bool
-	HasNext     bool
+	ID             int
+	URI            string
+	Title          string
+	Date           publishDate
+	Description    string
+	Images         []string
+	Content        template.HTML
+	Summary        template.HTML
+	Truncated      bool
+	Categories     []string
+	Tags           []string
+	Draft          bool
+	PublishDate    publishDate `yaml:"publishDate"`
+	Unlisted       bool
+	TOC            []*Heading `yaml:"-"`
+	Lang           string
+	TranslationKey string `yaml:"translationKey"`
+	Series         string
+	SeriesOrder    int `yaml:"seriesOrder"`
+	WordCount      int `yaml:"-"`
+	ReadingTime    int `yaml:"-"`
+}
+
+// FilterByLang returns the posts written in lang, all the posts are returned if lang is empty
+func FilterByLang(posts []*Post, lang string) []*Post {
+	if lang == "" {
+		return posts
+	}
+
+	var filtered []*Post
+	for _, p := range posts {
+		if p.Lang == lang {
+			filtered = append(filtered, p)
+		}
+	}
+
+	return filtered
+}
+
+// Heading is an entry in the table of contents of a post, Slug is the id of the rendered heading
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Slug  string `json:"slug"`
+}
+
+// IsPublished reports whether the post is live at the given time: drafts are never published,
+// scheduled posts are published once their publish date has passed
+func (p *Post) IsPublished(now time.Time) bool {
+	return !p.Draft && !p.PublishDate.After(now)
+}
+
+// IsListed reports whether the post is published and shown in the home page, tags, archives, sitemap and feeds
+func (p *Post) IsListed(now time.Time) bool {
+	return p.IsPublished(now) && !p.Unlisted
 }
 
 type publishDate struct {
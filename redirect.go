@@ -0,0 +1,9 @@
+package blog
+
+// RedirectService stores the permanent redirects of the renamed posts and assets
+type RedirectService interface {
+	// AddRedirect redirects from to to, the existing redirects to from are updated to redirect to to
+	AddRedirect(from, to string) error
+	// GetRedirect returns the target of from, it is empty if there is none
+	GetRedirect(from string) (string, error)
+}
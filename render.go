@@ -9,9 +9,12 @@ import (
 type Renderer interface {
 	RenderPhotos(w http.ResponseWriter) error
 	RenderTags(w http.ResponseWriter) error
+	RenderSeries(w http.ResponseWriter) error
 	RenderArchives(w http.ResponseWriter) error
 	RenderPosts(w http.ResponseWriter, r *http.Request, posts []*Post) error
+	RenderSearchResults(w http.ResponseWriter, r *http.Request, searchRequest *SearchRequest, result *SearchResult) error
 	RenderPost(w http.ResponseWriter, currentPost *Post, relatedPosts []*Post, previousPost, nextPost *Post) error
 	RenderResponseMessage(w http.ResponseWriter, contextualClass, message string) error
+	RenderResendConfirmation(w http.ResponseWriter, contextualClass, message, email string) error
 	RenderNewsletter(latestPosts []*Post, serverURL, email string) (*bytes.Buffer, error)
 }
@@ -0,0 +1,53 @@
+package blog
+
+import (
+	"html/template"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidQuery is returned when the query string cannot be parsed
+var ErrInvalidQuery = errors.New("invalid search query")
+
+const (
+	// SortByRelevance sorts the search results by score
+	SortByRelevance = "relevance"
+	// SortByDate sorts the search results by date, newest first
+	SortByDate = "date"
+)
+
+// SearchRequest represents a search with the bleve query string syntax: phrases, field:term, fuzzy term~ ...
+type SearchRequest struct {
+	Query string
+	Sort  string
+	Page  int
+	Size  int
+}
+
+// SearchResult represents a page of hits and the tags, categories and years facets of all the matching posts
+type SearchResult struct {
+	Total  int
+	Hits   []*SearchHit
+	Facets map[string][]*SearchFacet
+}
+
+// SearchHit represents a matching post, Section is the slug of the first matching heading
+type SearchHit struct {
+	URI       string
+	Score     float64
+	Fragments []template.HTML
+	Section   string
+	Post      *Post
+}
+
+// SearchFacet represents a term and the number of matching posts having it
+type SearchFacet struct {
+	Term  string
+	Count int
+}
+
+// Suggestions represents the posts whose title and the tags completing a search prefix
+type Suggestions struct {
+	URIs []string
+	Tags []string
+}
@@ -13,6 +13,14 @@ type Sitemap struct {
 
 // URL represents a site map URL
 type URL struct {
-	Loc     string `xml:"loc"`
-	LastMod string `xml:"lastmod,omitempty"`
+	Loc        string          `xml:"loc"`
+	LastMod    string          `xml:"lastmod,omitempty"`
+	Alternates []AlternateLink `xml:"http://www.w3.org/1999/xhtml link"`
+}
+
+// AlternateLink represents the page of a URL in a language, used by search engines to serve the right language
+type AlternateLink struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
 }
@@ -1,11 +1,21 @@
 package blog
 
+import "bytes"
+
 // SMTPService is the interface that wraps methods related to SMTP
 type SMTPService interface {
 	SendConfirmationEmail(to, token string) error
 	SendThankYouEmail(to string) error
 	SendNewsletter(posts []*Post)
+	// SendNewsletterEmail sends the newsletter rendered for the subscriber to,
+	// it is sent only once per key if the key is not empty
+	SendNewsletterEmail(to, key string, newsletter *bytes.Buffer) error
 	GenerateNewUUID() string
 	GetHMACSecret() string
 	Stop() error
 }
+
+// NewsletterRenderer renders the newsletter sent to a subscriber
+type NewsletterRenderer interface {
+	RenderNewsletter(latestPosts []*Post, serverURL, email string) (*bytes.Buffer, error)
+}
@@ -0,0 +1,132 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	gosmtp "net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+// TLS modes of the connection to the SMTP server
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+const sendTimeout = time.Minute
+
+// recipientError is returned when the server rejects the recipient
+type recipientError struct {
+	err error
+}
+
+func (e *recipientError) Error() string {
+	return fmt.Sprintf("recipient rejected: %v", e.err)
+}
+
+func (e *recipientError) Unwrap() error {
+	return e.err
+}
+
+// send sends the email in its own connection, the connection is secured as configured
+func (s *Service) send(e *blog.Email) error {
+	from, err := envelopeSender(s.config.Newsletter.From)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if s.config.SMTP.Username != "" {
+		auth := gosmtp.PlainAuth("", s.config.SMTP.Username, s.config.SMTP.Password, s.config.SMTP.Host)
+		if err := c.Auth(auth); err != nil {
+			return errors.Wrap(err, "failed to authenticate")
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(e.To); err != nil {
+		return &recipientError{err: err}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.Message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (s *Service) dial() (*gosmtp.Client, error) {
+	addr := net.JoinHostPort(s.config.SMTP.Host, strconv.Itoa(s.config.SMTP.Port))
+	dialer := &net.Dialer{Timeout: sendTimeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.config.SMTP.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", addr)
+	}
+	if err := conn.SetDeadline(time.Now().Add(sendTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c, err := gosmtp.NewClient(conn, s.config.SMTP.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	switch s.config.SMTP.TLS {
+	case TLSImplicit, TLSNone:
+	default:
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && s.config.SMTP.TLS == TLSStartTLS {
+			_ = c.Close()
+			return nil, errors.Errorf("%s does not support STARTTLS", addr)
+		}
+		if ok {
+			if err := c.StartTLS(s.tlsConfig); err != nil {
+				_ = c.Close()
+				return nil, errors.Wrap(err, "failed to start TLS")
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func envelopeSender(from string) (string, error) {
+	a, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid sender %s", from)
+	}
+
+	return a.Address, nil
+}
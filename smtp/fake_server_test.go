@@ -0,0 +1,219 @@
+package smtp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeServer is an in-memory SMTP server which records the messages it receives,
+// the replies to RCPT can be queued to simulate the transient and the permanent failures
+type fakeServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+
+	mu          sync.Mutex
+	messages    []fakeMessage
+	auths       []string
+	rcptReplies []string
+}
+
+// newFakeServer starts a server on a random port, the server supports STARTTLS unless it uses implicit TLS
+func newFakeServer(t *testing.T, implicit bool) *fakeServer {
+	t.Helper()
+
+	s := &fakeServer{
+		tlsConfig: newTLSConfig(t),
+		implicit:  implicit,
+	}
+
+	var err error
+	if implicit {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	} else {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.ln.Close()
+	})
+
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// clientTLSConfig returns a config trusting the certificate of the server
+func (s *fakeServer) clientTLSConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.tlsConfig.Certificates[0].Leaf)
+
+	return &tls.Config{
+		ServerName: "127.0.0.1",
+		RootCAs:    pool,
+	}
+}
+
+func (s *fakeServer) replyToRcpt(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rcptReplies = append(s.rcptReplies, replies...)
+}
+
+func (s *fakeServer) received() []fakeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]fakeMessage(nil), s.messages...)
+}
+
+func (s *fakeServer) authentications() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.auths...)
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP fake")
+
+	var msg fakeMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"250-localhost", "250-AUTH PLAIN"}
+			if _, ok := conn.(*tls.Conn); !ok && !s.implicit {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			extensions = append(extensions, "250 8BITMIME")
+			_ = tp.PrintfLine("%s", strings.Join(extensions, "\r\n"))
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			s.mu.Lock()
+			s.auths = append(s.auths, arg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			msg = fakeMessage{From: pathOf(arg)}
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			reply := "250 OK"
+			if len(s.rcptReplies) > 0 {
+				reply, s.rcptReplies = s.rcptReplies[0], s.rcptReplies[1:]
+			}
+			s.mu.Unlock()
+			if strings.HasPrefix(reply, "250") {
+				msg.To = append(msg.To, pathOf(arg))
+			}
+			_ = tp.PrintfLine("%s", reply)
+		case "DATA":
+			_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// pathOf returns the address of a MAIL or RCPT command: FROM:<address> [parameters]
+func pathOf(arg string) string {
+	_, path, _ := strings.Cut(arg, "<")
+	path, _, _ = strings.Cut(path, ">")
+	return path
+}
+
+// newTLSConfig returns a config with a self-signed certificate of 127.0.0.1
+func newTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+			Leaf:        leaf,
+		}},
+	}
+}
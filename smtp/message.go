@@ -0,0 +1,71 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/email"
+)
+
+const (
+	confirmationSubject = "Please confirm your subscription"
+	thankYouSubject     = "Thank you for subscribing"
+)
+
+var (
+	confirmationTemplate = template.Must(template.New("confirmation").Parse(`<p>Thank you for subscribing to {{ .product }}.</p>
+<p>Please <a href="{{ .link }}">click here</a> to confirm your subscription.</p>
+<p>If you didn't subscribe, you can safely ignore this email.</p>
+`))
+	thankYouTemplate = template.Must(template.New("thankyou").Parse(`<p>Your subscription to {{ .product }} is confirmed.</p>
+<p>You will receive an email when new posts are published on <a href="{{ .url }}">{{ .url }}</a>.</p>
+`))
+)
+
+func (s *Service) enqueueTemplate(to, subject string, tmpl *template.Template, data map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return errors.Wrapf(err, "failed to execute template %s", tmpl.Name())
+	}
+
+	message, err := newMessage(s.config, to, subject, buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(to, "", subject, message)
+}
+
+func confirmationURL(baseURL, token string) string {
+	return fmt.Sprintf("%s/subscriptions/confirm?token=%s", strings.TrimSuffix(baseURL, "/"), url.QueryEscape(token))
+}
+
+// newMessage returns an HTML message with its headers
+func newMessage(config *blog.Config, to, subject string, html []byte) ([]byte, error) {
+	msg := &email.Message{
+		From:    config.Newsletter.From,
+		To:      to,
+		Subject: subject,
+		HTML:    html,
+	}
+
+	return msg.Bytes()
+}
+
+// subjectOf returns the decoded subject of a message
+func subjectOf(message []byte) (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read message")
+	}
+
+	return new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+}
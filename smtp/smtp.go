@@ -0,0 +1,290 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	maxSendAttempts = 5
+	// sendRetryDelay is the delay before the second attempt, it is doubled after each failed attempt
+	sendRetryDelay = time.Minute
+	pollInterval   = time.Minute
+	drainTimeout   = 30 * time.Second
+	batchSize      = 100
+)
+
+// Service sends the emails of the blog: they are stored in the outbound queue first,
+// then a worker sends them one by one, waiting for the limiter interval between two emails
+type Service struct {
+	logger      zerolog.Logger
+	config      *blog.Config
+	outbox      blog.OutboxService
+	subscribers blog.SubscriberService
+	renderer    blog.NewsletterRenderer
+
+	tlsConfig *tls.Config
+	now       func() time.Time
+	lastSent  time.Time
+
+	queued   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	started  atomic.Bool
+	stopOnce sync.Once
+}
+
+// NewSMTPService returns a service sending the emails through the SMTP server of the config,
+// the newsletters are rendered by renderer and sent to the active subscribers
+func NewSMTPService(logger zerolog.Logger, config *blog.Config, outbox blog.OutboxService, subscribers blog.SubscriberService, renderer blog.NewsletterRenderer) *Service {
+	return &Service{
+		logger:      logger,
+		config:      config,
+		outbox:      outbox,
+		subscribers: subscribers,
+		renderer:    renderer,
+		tlsConfig: &tls.Config{
+			ServerName: config.SMTP.Host,
+		},
+		now:    time.Now,
+		queued: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start starts the worker sending the queued emails
+func (s *Service) Start() {
+	if s.started.CompareAndSwap(false, true) {
+		go s.run()
+	}
+}
+
+// Stop stops the worker once the due emails are sent, the emails which are not sent within the drain timeout
+// or are waiting for a retry stay in the queue until the next start
+func (s *Service) Stop() error {
+	if !s.started.Load() {
+		return nil
+	}
+
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	return nil
+}
+
+func (s *Service) SendConfirmationEmail(to, token string) error {
+	return s.enqueueTemplate(to, confirmationSubject, confirmationTemplate, map[string]interface{}{
+		"product": s.config.Newsletter.Product.Name,
+		"link":    confirmationURL(s.config.Site.BaseURL, token),
+	})
+}
+
+func (s *Service) SendThankYouEmail(to string) error {
+	return s.enqueueTemplate(to, thankYouSubject, thankYouTemplate, map[string]interface{}{
+		"product": s.config.Newsletter.Product.Name,
+		"url":     s.config.Site.BaseURL,
+	})
+}
+
+// SendNewsletter queues the newsletter of the posts for each active subscriber
+func (s *Service) SendNewsletter(posts []*blog.Post) {
+	if len(posts) == 0 || s.subscribers == nil {
+		return
+	}
+
+	subscribers, err := s.subscribers.ActiveSubscribers()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to get active subscribers")
+		return
+	}
+
+	for _, subscriber := range subscribers {
+		body, err := s.renderer.RenderNewsletter(posts, s.config.Site.BaseURL, subscriber.Email)
+		if err != nil {
+			s.logger.Error().Err(err).Str("email", subscriber.Email).Msg("failed to render newsletter")
+			continue
+		}
+
+		if err := s.SendNewsletterEmail(subscriber.Email, "", body); err != nil {
+			s.logger.Error().Err(err).Str("email", subscriber.Email).Msg("failed to queue newsletter")
+		}
+	}
+}
+
+// SendNewsletterEmail queues the newsletter, it is a whole message rendered by the NewsletterRenderer.
+// A newsletter whose key has already been queued is not queued again.
+func (s *Service) SendNewsletterEmail(to, key string, newsletter *bytes.Buffer) error {
+	subject, err := subjectOf(newsletter.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(to, key, subject, newsletter.Bytes())
+}
+
+func (s *Service) GenerateNewUUID() string {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to generate UUID")
+	}
+
+	return id
+}
+
+func (s *Service) GetHMACSecret() string {
+	return s.config.Newsletter.HMAC.Secret
+}
+
+func (s *Service) enqueue(to, key, subject string, message []byte) error {
+	now := s.now()
+	if err := s.outbox.EnqueueEmail(&blog.Email{
+		To:            to,
+		Subject:       subject,
+		Message:       message,
+		Key:           key,
+		Status:        blog.EmailPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (s *Service) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.processDueEmails(s.stop); err != nil {
+			s.logger.Error().Err(err).Msg("failed to send queued emails")
+		}
+
+		select {
+		case <-s.stop:
+			abort := make(chan struct{})
+			timer := time.AfterFunc(drainTimeout, func() {
+				close(abort)
+			})
+			defer timer.Stop()
+			if err := s.processDueEmails(abort); err != nil {
+				s.logger.Error().Err(err).Msg("failed to send queued emails")
+			}
+			return
+		case <-ticker.C:
+		case <-s.queued:
+		}
+	}
+}
+
+// processDueEmails sends the due emails until there is none left or abort is closed
+func (s *Service) processDueEmails(abort <-chan struct{}) error {
+	for {
+		emails, err := s.outbox.DueEmails(s.now(), batchSize)
+		if err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+
+		for _, e := range emails {
+			if !s.wait(abort) {
+				return nil
+			}
+			if err := s.processEmail(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// wait waits for the limiter interval since the last email, it returns false if abort is closed first
+func (s *Service) wait(abort <-chan struct{}) bool {
+	select {
+	case <-abort:
+		return false
+	default:
+	}
+
+	d := s.lastSent.Add(s.config.Newsletter.Limiter.Interval).Sub(s.now())
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-abort:
+		return false
+	}
+}
+
+// processEmail sends the email, then records the result: a transient failure is retried with an exponential backoff,
+// the recipient of an email rejected permanently is marked as bounced
+func (s *Service) processEmail(e *blog.Email) error {
+	start := s.now()
+	err := s.send(e)
+	s.lastSent = s.now()
+
+	e.Attempts++
+	switch {
+	case err == nil:
+		e.Status = blog.EmailSent
+		e.Error = ""
+		e.SentAt = &start
+	case isTransient(err) && e.Attempts < maxSendAttempts:
+		e.Error = err.Error()
+		e.NextAttemptAt = start.Add(sendRetryDelay << (e.Attempts - 1))
+	default:
+		e.Status = blog.EmailFailed
+		e.Error = err.Error()
+		var rcptErr *recipientError
+		if errors.As(err, &rcptErr) && !isTransient(err) && s.subscribers != nil {
+			if err := s.subscribers.MarkBounced(e.To); err != nil {
+				s.logger.Error().Err(err).Str("email", e.To).Msg("failed to mark subscriber as bounced")
+			}
+		}
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Int64("id", e.ID).Str("email", e.To).Int("attempts", e.Attempts).Msg("failed to send email")
+	}
+
+	return s.outbox.UpdateEmail(e)
+}
+
+// isTransient reports whether sending again later may succeed: the server replied with a 4xx code
+// or the network timed out, any other error fails the email at once
+func isTransient(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return 400 <= protoErr.Code && protoErr.Code <= 499
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
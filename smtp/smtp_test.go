@@ -0,0 +1,257 @@
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/email"
+)
+
+type fakeOutboxService struct {
+	mu     sync.Mutex
+	emails []*blog.Email
+}
+
+func (s *fakeOutboxService) EnqueueEmail(e *blog.Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = int64(len(s.emails) + 1)
+	c := *e
+	s.emails = append(s.emails, &c)
+	return nil
+}
+
+func (s *fakeOutboxService) UpdateEmail(e *blog.Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *e
+	s.emails[e.ID-1] = &c
+	return nil
+}
+
+func (s *fakeOutboxService) DueEmails(now time.Time, limit int) ([]*blog.Email, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emails []*blog.Email
+	for _, e := range s.emails {
+		if e.Status == blog.EmailPending && !e.NextAttemptAt.After(now) && len(emails) < limit {
+			c := *e
+			emails = append(emails, &c)
+		}
+	}
+	return emails, nil
+}
+
+func (s *fakeOutboxService) email(id int64) *blog.Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.emails[id-1]
+}
+
+type fakeSubscriberService struct {
+	active  []*blog.Subscriber
+	bounced []string
+}
+
+func (s *fakeSubscriberService) FindSubscriber(email string) (*blog.Subscriber, error) {
+	return nil, nil
+}
+
+func (s *fakeSubscriberService) ActiveSubscribers() ([]*blog.Subscriber, error) {
+	return s.active, nil
+}
+
+func (s *fakeSubscriberService) MarkBounced(email string) error {
+	s.bounced = append(s.bounced, email)
+	return nil
+}
+
+type fakeRenderer struct{}
+
+func (r *fakeRenderer) RenderNewsletter(latestPosts []*blog.Post, serverURL, to string) (*bytes.Buffer, error) {
+	msg := &email.Message{
+		From:    "Blog <blog@example.com>",
+		To:      to,
+		Subject: "New posts on Blog",
+		HTML:    []byte(fmt.Sprintf("<p>%d posts on %s for %s</p>", len(latestPosts), serverURL, to)),
+	}
+	message, err := msg.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(message), nil
+}
+
+func newTestService(t *testing.T, server *fakeServer, tls string) (*Service, *fakeOutboxService, *fakeSubscriberService) {
+	t.Helper()
+
+	config := &blog.Config{}
+	config.Site.BaseURL = "https://blog.example.com"
+	config.SMTP.Host = "127.0.0.1"
+	config.SMTP.Port = server.port()
+	config.SMTP.Username = "user"
+	config.SMTP.Password = "password"
+	config.SMTP.TLS = tls
+	config.Newsletter.From = "Blog <blog@example.com>"
+	config.Newsletter.Product.Name = "Blog"
+
+	outbox := &fakeOutboxService{}
+	subscribers := &fakeSubscriberService{}
+	s := NewSMTPService(zerolog.Nop(), config, outbox, subscribers, &fakeRenderer{})
+	s.tlsConfig = server.clientTLSConfig()
+
+	return s, outbox, subscribers
+}
+
+func decodeBody(t *testing.T, data string) (*mail.Message, string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bytes.NewBufferString(data))
+	require.NoError(t, err)
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	require.NoError(t, err)
+
+	return msg, string(body)
+}
+
+func TestService(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		tls      string
+		implicit bool
+	}{
+		{name: "starttls", tls: TLSStartTLS},
+		{name: "opportunistic starttls"},
+		{name: "implicit tls", tls: TLSImplicit, implicit: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeServer(t, tc.implicit)
+			s, _, _ := newTestService(t, server, tc.tls)
+			s.Start()
+
+			require.NoError(t, s.SendConfirmationEmail("foo@example.com", "abc=def"))
+			require.Eventually(t, func() bool {
+				return len(server.received()) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			require.NoError(t, s.Stop())
+
+			received := server.received()[0]
+			assert.Equal(t, "blog@example.com", received.From)
+			assert.Equal(t, []string{"foo@example.com"}, received.To)
+			msg, body := decodeBody(t, received.Data)
+			assert.Equal(t, confirmationSubject, msg.Header.Get("Subject"))
+			assert.Equal(t, `"Blog" <blog@example.com>`, msg.Header.Get("From"))
+			assert.Contains(t, body, `href="https://blog.example.com/subscriptions/confirm?token=abc%3Ddef"`)
+			assert.Len(t, server.authentications(), 1)
+		})
+	}
+}
+
+func TestServiceRetry(t *testing.T) {
+	server := newFakeServer(t, false)
+	server.replyToRcpt("451 4.7.1 Try again later", "550 5.1.1 No such user")
+	s, outbox, subscribers := newTestService(t, server, TLSStartTLS)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		return now
+	}
+
+	require.NoError(t, s.SendThankYouEmail("foo@example.com"))
+	require.NoError(t, s.SendThankYouEmail("bar@example.com"))
+	require.NoError(t, s.processDueEmails(make(chan struct{})))
+
+	foo := outbox.email(1)
+	assert.Equal(t, blog.EmailPending, foo.Status)
+	assert.Equal(t, 1, foo.Attempts)
+	assert.Contains(t, foo.Error, "451")
+	assert.Equal(t, now.Add(sendRetryDelay), foo.NextAttemptAt)
+	bar := outbox.email(2)
+	assert.Equal(t, blog.EmailFailed, bar.Status)
+	assert.Contains(t, bar.Error, "550")
+	assert.Equal(t, []string{"bar@example.com"}, subscribers.bounced)
+	assert.Empty(t, server.received())
+
+	require.NoError(t, s.processDueEmails(make(chan struct{})))
+	assert.Empty(t, server.received())
+
+	now = now.Add(sendRetryDelay)
+	require.NoError(t, s.processDueEmails(make(chan struct{})))
+	foo = outbox.email(1)
+	assert.Equal(t, blog.EmailSent, foo.Status)
+	assert.Equal(t, 2, foo.Attempts)
+	assert.Empty(t, foo.Error)
+	require.NotNil(t, foo.SentAt)
+	require.Len(t, server.received(), 1)
+	assert.Equal(t, []string{"foo@example.com"}, server.received()[0].To)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(fmt.Errorf("rcpt: %w", &textproto.Error{Code: 451, Msg: "4.7.1 Try again later"})))
+	assert.False(t, isTransient(&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}))
+	assert.True(t, isTransient(&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}))
+	assert.False(t, isTransient(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}))
+	assert.False(t, isTransient(errors.New("invalid address")))
+}
+
+func TestServiceStop(t *testing.T) {
+	server := newFakeServer(t, false)
+	s, _, _ := newTestService(t, server, TLSStartTLS)
+	s.config.Newsletter.Limiter.Interval = 20 * time.Millisecond
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	for _, to := range recipients {
+		require.NoError(t, s.SendThankYouEmail(to))
+	}
+
+	start := time.Now()
+	s.Start()
+	require.NoError(t, s.Stop())
+	assert.GreaterOrEqual(t, time.Since(start), 2*s.config.Newsletter.Limiter.Interval)
+
+	var received []string
+	for _, msg := range server.received() {
+		received = append(received, msg.To...)
+	}
+	sort.Strings(received)
+	assert.Equal(t, recipients, received)
+}
+
+func TestServiceSendNewsletter(t *testing.T) {
+	server := newFakeServer(t, false)
+	s, outbox, subscribers := newTestService(t, server, TLSStartTLS)
+	subscribers.active = []*blog.Subscriber{
+		{Email: "foo@example.com", Status: blog.SubscriberActive},
+		{Email: "bar@example.com", Status: blog.SubscriberActive},
+	}
+
+	s.SendNewsletter(nil)
+	assert.Empty(t, outbox.emails)
+
+	s.SendNewsletter([]*blog.Post{{Title: "Hello"}})
+	require.Len(t, outbox.emails, 2)
+	assert.Equal(t, "bar@example.com", outbox.emails[1].To)
+	assert.Equal(t, "New posts on Blog", outbox.emails[1].Subject)
+	_, body := decodeBody(t, string(outbox.emails[1].Message))
+	assert.Equal(t, "<p>1 posts on https://blog.example.com for bar@example.com</p>", body)
+}
@@ -0,0 +1,40 @@
+package blog
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ContentSource provides the files of the blog: the markdown files of the posts and their images
+type ContentSource interface {
+	// FS returns the current version of the files, the paths are the URIs of the posts without the leading slash
+	FS() fs.FS
+	// Update fetches the latest version of the files
+	Update(ctx context.Context) error
+}
+
+// Actions of a content change
+const (
+	ContentAdded    = "added"
+	ContentModified = "modified"
+	ContentRenamed  = "renamed"
+	ContentDeleted  = "deleted"
+)
+
+// ContentChange is a file changed between two versions of the content
+type ContentChange struct {
+	Action string
+	// From is the path before the change, it is empty if the file is added
+	From string
+	// To is the path after the change, it is empty if the file is deleted
+	To string
+}
+
+// VersionedContentSource is a content source whose versions can be compared, such as a git repository
+type VersionedContentSource interface {
+	ContentSource
+	// Version returns the current version of the content, it is empty if the content is not versioned
+	Version() (string, error)
+	// Diff returns the files changed between two versions, the renamed files are detected
+	Diff(from, to string) ([]ContentChange, error)
+}
@@ -0,0 +1,156 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+type archiveSource struct {
+	name string
+
+	mu   sync.RWMutex
+	fsys fs.FS
+}
+
+// NewArchive returns a source reading a .zip, .tar, .tar.gz or .tgz archive, the files are kept in memory
+func NewArchive(name string) (blog.ContentSource, error) {
+	s := &archiveSource{
+		name: name,
+	}
+	if err := s.Update(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *archiveSource) FS() fs.FS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fsys
+}
+
+// Update reads the archive again, so it can be replaced by a newer one while the blog is running
+func (s *archiveSource) Update(ctx context.Context) error {
+	fsys, err := openArchive(s.name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.fsys = fsys
+	s.mu.Unlock()
+
+	return nil
+}
+
+func openArchive(name string) (fs.FS, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read archive %s", name)
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open zip archive %s", name)
+		}
+		return r, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open gzip archive %s", name)
+		}
+		defer gr.Close()
+		return readTar(name, gr)
+	case strings.HasSuffix(name, ".tar"):
+		return readTar(name, bytes.NewReader(data))
+	default:
+		return nil, errors.Errorf("unknown archive format: %s", name)
+	}
+}
+
+// readTar copies the regular files of the tarball into an in-memory zip archive, which can be opened as a fs.FS
+func readTar(name string, r io.Reader) (fs.FS, error) {
+	z := newZipFS()
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read tar archive %s", name)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := z.add(header.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	return z.fs()
+}
+
+// zipFS writes the files into an in-memory zip archive, since zip.Reader implements fs.FS
+type zipFS struct {
+	buf bytes.Buffer
+	w   *zip.Writer
+}
+
+func newZipFS() *zipFS {
+	z := new(zipFS)
+	z.w = zip.NewWriter(&z.buf)
+
+	return z
+}
+
+func (z *zipFS) add(name string, r io.Reader) error {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+
+	w, err := z.w.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to add %s", name)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.Wrapf(err, "failed to copy %s", name)
+	}
+
+	return nil
+}
+
+func (z *zipFS) fs() (fs.FS, error) {
+	if err := z.w.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close zip writer")
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(z.buf.Bytes()), int64(z.buf.Len()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open zip archive")
+	}
+
+	return r, nil
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+// diffCommits returns the files changed between two commits, a file whose path has changed is renamed
+func diffCommits(repo *git.Repository, from, to string) ([]blog.ContentChange, error) {
+	fromTree, err := commitTree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := fromTree.DiffContext(context.Background(), toTree)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to diff %s and %s", from, to)
+	}
+
+	contentChanges := make([]blog.ContentChange, 0, len(changes))
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		switch action {
+		case merkletrie.Insert:
+			contentChanges = append(contentChanges, blog.ContentChange{Action: blog.ContentAdded, To: change.To.Name})
+		case merkletrie.Delete:
+			contentChanges = append(contentChanges, blog.ContentChange{Action: blog.ContentDeleted, From: change.From.Name})
+		case merkletrie.Modify:
+			contentChange := blog.ContentChange{Action: blog.ContentModified, From: change.From.Name, To: change.To.Name}
+			if change.From.Name != change.To.Name {
+				contentChange.Action = blog.ContentRenamed
+			}
+			contentChanges = append(contentChanges, contentChange)
+		}
+	}
+
+	return contentChanges, nil
+}
+
+func commitTree(repo *git.Repository, revision string) (*object.Tree, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s", revision)
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get commit %s", hash)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tree of commit %s", hash)
+	}
+
+	return tree, nil
+}
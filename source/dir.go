@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+type dirSource struct {
+	dir    string
+	branch string
+	fsys   fs.FS
+}
+
+// NewDir returns a source reading the files in a local directory, it is versioned if the directory is a git checkout.
+// Branch is the branch of the origin the checkout is reset to when it is updated.
+func NewDir(dir, branch string) blog.VersionedContentSource {
+	return &dirSource{
+		dir:    dir,
+		branch: branch,
+		fsys:   os.DirFS(dir),
+	}
+}
+
+func (s *dirSource) FS() fs.FS {
+	return s.fsys
+}
+
+// Update resets the directory to the branch of its origin if it is a git checkout
+func (s *dirSource) Update(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); os.IsNotExist(err) {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf("git -C %s fetch origin && git -C %s reset --hard origin/%s", s.dir, s.dir, s.branch))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch the updated content: %s", output)
+	}
+
+	return nil
+}
+
+// Version returns the HEAD commit of the checkout
+func (s *dirSource) Version() (string, error) {
+	repo, err := git.PlainOpen(s.dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open git repository %s", s.dir)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get HEAD of %s", s.dir)
+	}
+
+	return head.Hash().String(), nil
+}
+
+func (s *dirSource) Diff(from, to string) ([]blog.ContentChange, error) {
+	repo, err := git.PlainOpen(s.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open git repository %s", s.dir)
+	}
+
+	return diffCommits(repo, from, to)
+}
+
+type fsSource struct {
+	fsys fs.FS
+}
+
+// NewFS returns a source reading a file system which never changes, such as the posts embedded into the binary
+func NewFS(fsys fs.FS) blog.ContentSource {
+	return &fsSource{
+		fsys: fsys,
+	}
+}
+
+func (s *fsSource) FS() fs.FS {
+	return s.fsys
+}
+
+func (s *fsSource) Update(ctx context.Context) error {
+	return nil
+}
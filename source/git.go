@@ -0,0 +1,125 @@
+package source
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+const defaultRef = "HEAD"
+
+type gitSource struct {
+	repo *git.Repository
+	ref  string
+
+	mu   sync.RWMutex
+	fsys fs.FS
+	// commit is the hash of the commit whose tree is read
+	commit string
+}
+
+// NewGit returns a source reading the tree of a ref in a git repository, which is usually a bare clone.
+// The files are read in-process, neither a working tree nor the git CLI is needed.
+func NewGit(repository, ref string) (blog.VersionedContentSource, error) {
+	repo, err := git.PlainOpen(repository)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open git repository %s", repository)
+	}
+
+	if ref == "" {
+		ref = defaultRef
+	}
+	s := &gitSource{
+		repo: repo,
+		ref:  ref,
+	}
+	if err := s.checkout(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *gitSource) FS() fs.FS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fsys
+}
+
+func (s *gitSource) Version() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.commit, nil
+}
+
+func (s *gitSource) Diff(from, to string) ([]blog.ContentChange, error) {
+	return diffCommits(s.repo, from, to)
+}
+
+// Update fetches the branches from the origin if there is one, then reads the tree of the ref again
+func (s *gitSource) Update(ctx context.Context) error {
+	if _, err := s.repo.Remote(git.DefaultRemoteName); err == nil {
+		err := s.repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{"+refs/heads/*:refs/heads/*"},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return errors.Wrapf(err, "failed to fetch from %s", git.DefaultRemoteName)
+		}
+	} else if !errors.Is(err, git.ErrRemoteNotFound) {
+		return errors.Wrapf(err, "failed to get remote %s", git.DefaultRemoteName)
+	}
+
+	return s.checkout()
+}
+
+// checkout reads the files of the tree of the ref into memory
+func (s *gitSource) checkout() error {
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(s.ref))
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve %s", s.ref)
+	}
+
+	tree, err := commitTree(s.repo, hash.String())
+	if err != nil {
+		return err
+	}
+
+	z := newZipFS()
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() {
+			return nil
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", f.Name)
+		}
+		defer r.Close()
+
+		return z.add(f.Name, r)
+	}); err != nil {
+		return err
+	}
+
+	fsys, err := z.fs()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.fsys = fsys
+	s.commit = hash.String()
+	s.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"io/fs"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/blog"
+)
+
+const (
+	// Dir is the name of the source reading a local directory
+	Dir = "dir"
+	// Embed is the name of the source reading the posts embedded into the binary
+	Embed = "embed"
+	// Archive is the name of the source reading a tarball or a zip archive
+	Archive = "archive"
+	// Git is the name of the source reading a git repository at a ref
+	Git = "git"
+
+	branchRefPrefix = "refs/heads/"
+)
+
+// New returns the content source set in the config, embedded is the file system of the posts embedded into the binary
+func New(config *blog.Config, embedded fs.FS) (blog.ContentSource, error) {
+	switch config.Posts.Source {
+	case "", Dir:
+		if err := checkPushBranches(config, config.Branch()); err != nil {
+			return nil, err
+		}
+		return NewDir(config.Posts.Dir, config.Branch()), nil
+	case Embed:
+		if embedded == nil {
+			return nil, errors.New("no posts are embedded into the binary")
+		}
+		return NewFS(embedded), nil
+	case Archive:
+		return NewArchive(config.Posts.Archive)
+	case Git:
+		ref := config.Posts.Git.Ref
+		if ref == "" {
+			ref = config.Webhook.Branch
+		}
+		if ref != "" {
+			if err := checkPushBranches(config, strings.TrimPrefix(ref, branchRefPrefix)); err != nil {
+				return nil, err
+			}
+		}
+		return NewGit(config.Posts.Git.Repository, ref)
+	default:
+		return nil, errors.Errorf("unknown content source: %s", config.Posts.Source)
+	}
+}
+
+// checkPushBranches returns an error if an enabled webhook provider processes the pushes to another branch
+// than the one the content is fetched from, since the pushed changes would never be read
+func checkPushBranches(config *blog.Config, branch string) error {
+	github := config.Webhook.GitHub
+	if github.Secret == "" {
+		github.Secret = config.Webhook.Secret
+	}
+	for _, provider := range []blog.WebhookProvider{github, config.Webhook.Gitea, config.Webhook.GitLab, config.Webhook.Generic} {
+		if provider.Secret == "" {
+			continue
+		}
+		if pushBranch := config.PushBranch(provider); pushBranch != branch {
+			return errors.Errorf("the webhooks process the pushes to %s but the content is fetched from %s", pushBranch, branch)
+		}
+	}
+
+	return nil
+}
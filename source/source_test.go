@@ -0,0 +1,253 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+const postName = "2021/01/01/hello.md"
+
+func TestNew(t *testing.T) {
+	config := &blog.Config{}
+	config.Posts.Dir = t.TempDir()
+	contentSource, err := New(config, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &dirSource{}, contentSource)
+	assert.NoError(t, contentSource.Update(context.Background()))
+
+	config.Posts.Source = Embed
+	_, err = New(config, nil)
+	assert.Error(t, err)
+
+	contentSource, err = New(config, fstest.MapFS{})
+	require.NoError(t, err)
+	assert.IsType(t, &fsSource{}, contentSource)
+
+	config.Posts.Source = "unknown"
+	_, err = New(config, nil)
+	assert.Error(t, err)
+
+	// the webhooks must process the pushes to the branch the content is fetched from
+	config.Posts.Source = Dir
+	config.Webhook.Secret = "secret"
+	config.Webhook.GitHub.Branch = "release"
+	_, err = New(config, nil)
+	assert.Error(t, err)
+	config.Webhook.Branch = "release"
+	contentSource, err = New(config, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "release", contentSource.(*dirSource).branch)
+	config.Posts.Source = Git
+	config.Posts.Git.Ref = "refs/heads/main"
+	_, err = New(config, nil)
+	assert.ErrorContains(t, err, "the content is fetched from main")
+}
+
+func TestDirUpdate(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "blog-posts")
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitPost(t, repo, workDir, "v1")
+
+	checkoutDir := filepath.Join(t.TempDir(), "posts")
+	_, err = git.PlainClone(checkoutDir, false, &git.CloneOptions{
+		URL: workDir,
+	})
+	require.NoError(t, err)
+
+	// go-git initializes the repository on master
+	contentSource := NewDir(checkoutDir, "master")
+	commitPost(t, repo, workDir, "v2")
+	require.NoError(t, contentSource.Update(context.Background()))
+	assertContent(t, contentSource.FS(), "v2")
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"posts.zip", "posts.tar", "posts.tar.gz", "posts.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			name := filepath.Join(dir, name)
+			writeArchive(t, name, "v1")
+			contentSource, err := NewArchive(name)
+			require.NoError(t, err)
+			assertContent(t, contentSource.FS(), "v1")
+
+			writeArchive(t, name, "v2")
+			require.NoError(t, contentSource.Update(context.Background()))
+			assertContent(t, contentSource.FS(), "v2")
+		})
+	}
+
+	_, err := NewArchive(filepath.Join(dir, "posts.rar"))
+	assert.Error(t, err)
+}
+
+func TestGit(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "blog-posts")
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitPost(t, repo, workDir, "v1")
+
+	bareDir := filepath.Join(t.TempDir(), "blog-posts.git")
+	_, err = git.PlainClone(bareDir, true, &git.CloneOptions{
+		URL: workDir,
+	})
+	require.NoError(t, err)
+
+	contentSource, err := NewGit(bareDir, "")
+	require.NoError(t, err)
+	assertContent(t, contentSource.FS(), "v1")
+
+	commitPost(t, repo, workDir, "v2")
+	require.NoError(t, contentSource.Update(context.Background()))
+	assertContent(t, contentSource.FS(), "v2")
+	require.NoError(t, contentSource.Update(context.Background()))
+
+	_, err = NewGit(bareDir, "unknown")
+	assert.Error(t, err)
+}
+
+func assertContent(t *testing.T, fsys fs.FS, content string) {
+	t.Helper()
+
+	data, err := fs.ReadFile(fsys, postName)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	entries, err := fs.ReadDir(fsys, "2021/01")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].IsDir())
+}
+
+func writeArchive(t *testing.T, name, content string) {
+	t.Helper()
+
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	switch filepath.Ext(name) {
+	case ".zip":
+		zw := zip.NewWriter(f)
+		w, err := zw.Create(postName)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	case ".tar":
+		writeTar(t, f, content)
+	default:
+		gw := gzip.NewWriter(f)
+		writeTar(t, gw, content)
+		require.NoError(t, gw.Close())
+	}
+}
+
+func writeTar(t *testing.T, w io.Writer, content string) {
+	tw := tar.NewWriter(w)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "./2021/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "./" + postName,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(content)),
+	}))
+	_, err := io.WriteString(tw, content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+}
+
+func commitPost(t *testing.T, repo *git.Repository, workDir, content string) {
+	t.Helper()
+
+	name := filepath.Join(workDir, filepath.FromSlash(postName))
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add(postName)
+	require.NoError(t, err)
+	commit(t, repo, content)
+}
+
+func commit(t *testing.T, repo *git.Repository, message string) {
+	t.Helper()
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "Test",
+			Email: "test@example.com",
+			When:  time.Now(),
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "blog-posts")
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitPost(t, repo, workDir, "v1")
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "photo.png"), []byte("png"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "removed.md"), []byte("removed"), 0644))
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add(".")
+	require.NoError(t, err)
+	commit(t, repo, "assets")
+
+	contentSource := NewDir(workDir, blog.DefaultBranch)
+	from, err := contentSource.Version()
+	require.NoError(t, err)
+
+	_, err = wt.Move(postName, "2021/02/01/hello.md")
+	require.NoError(t, err)
+	_, err = wt.Remove("removed.md")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "photo.png"), []byte("new png"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "added.md"), []byte("added"), 0644))
+	_, err = wt.Add(".")
+	require.NoError(t, err)
+	commit(t, repo, "changes")
+
+	to, err := contentSource.Version()
+	require.NoError(t, err)
+	assert.NotEqual(t, from, to)
+
+	changes, err := contentSource.Diff(from, to)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []blog.ContentChange{
+		{Action: blog.ContentRenamed, From: postName, To: "2021/02/01/hello.md"},
+		{Action: blog.ContentDeleted, From: "removed.md"},
+		{Action: blog.ContentModified, From: "photo.png", To: "photo.png"},
+		{Action: blog.ContentAdded, To: "added.md"},
+	}, changes)
+
+	version, err := NewDir(t.TempDir(), blog.DefaultBranch).Version()
+	require.NoError(t, err)
+	assert.Empty(t, version)
+}
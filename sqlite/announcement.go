@@ -0,0 +1,42 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+type announcementService struct {
+	db *DB
+}
+
+// NewAnnouncementService returns a service recording into SQLite the time up to which the posts have been announced
+func NewAnnouncementService(db *DB) blog.AnnouncementService {
+	return &announcementService{
+		db: db,
+	}
+}
+
+func (s *announcementService) LastAnnounced() (time.Time, error) {
+	var t time.Time
+	err := s.db.sqlDB.QueryRow(`SELECT announced_until FROM announcements WHERE id = 1`).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to find the last announcement: %w", err)
+	}
+
+	return t, nil
+}
+
+func (s *announcementService) SetLastAnnounced(t time.Time) error {
+	if _, err := s.db.sqlDB.Exec(`INSERT OR REPLACE INTO announcements (id, announced_until) VALUES (1, ?)`, t.UTC()); err != nil {
+		return fmt.Errorf("failed to update announcements table: %w", err)
+	}
+
+	return nil
+}
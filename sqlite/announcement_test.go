@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncementService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	as := NewAnnouncementService(db)
+
+	last, err := as.LastAnnounced()
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, as.SetLastAnnounced(now))
+	require.NoError(t, as.SetLastAnnounced(now.Add(time.Minute)))
+	last, err = as.LastAnnounced()
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Minute).Equal(last))
+}
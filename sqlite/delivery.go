@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+type deliveryService struct {
+	db *DB
+}
+
+// NewDeliveryService returns a service recording the webhook deliveries into SQLite
+func NewDeliveryService(db *DB) blog.DeliveryService {
+	return &deliveryService{
+		db: db,
+	}
+}
+
+// CreateDelivery inserts a new delivery, it is ignored if the ID already exists
+func (s *deliveryService) CreateDelivery(d *blog.Delivery) error {
+	result, err := s.db.sqlDB.Exec(`
+INSERT OR IGNORE INTO deliveries (id, provider, branch, before_commit, after_commit, push, status, attempts, error, duration_ms, created_at, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.Provider, d.Branch, d.Before, d.After, d.Push, d.Status, d.Attempts, d.Error, d.Duration.Milliseconds(), d.CreatedAt.UTC(), d.NextAttemptAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into deliveries table: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return blog.ErrDeliveryExists
+	}
+
+	return nil
+}
+
+func (s *deliveryService) UpdateDelivery(d *blog.Delivery) error {
+	_, err := s.db.sqlDB.Exec(`
+UPDATE deliveries
+SET status = ?, attempts = ?, error = ?, duration_ms = ?, next_attempt_at = ?, reloaded = ?, announce = ?
+WHERE id = ?`,
+		d.Status, d.Attempts, d.Error, d.Duration.Milliseconds(), d.NextAttemptAt.UTC(), d.Reloaded, strings.Join(d.Announce, "\n"), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery %s: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+func (s *deliveryService) IsCommitDelivered(commit string) (bool, error) {
+	var n int
+	if err := s.db.sqlDB.QueryRow(`SELECT COUNT(*) FROM deliveries WHERE after_commit = ? AND status IN (?, ?)`,
+		commit, blog.DeliveryPending, blog.DeliverySucceeded).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to find deliveries of commit %s: %w", commit, err)
+	}
+
+	return n > 0, nil
+}
+
+func (s *deliveryService) DueDeliveries(now time.Time) ([]*blog.Delivery, error) {
+	return s.findDeliveries(`WHERE status = ? AND next_attempt_at <= ? ORDER BY created_at`, blog.DeliveryPending, now.UTC())
+}
+
+func (s *deliveryService) RecentDeliveries(limit int) ([]*blog.Delivery, error) {
+	return s.findDeliveries(`ORDER BY created_at DESC LIMIT ?`, limit)
+}
+
+func (s *deliveryService) findDeliveries(where string, args ...interface{}) ([]*blog.Delivery, error) {
+	rows, err := s.db.sqlDB.Query(`
+SELECT id, provider, branch, before_commit, after_commit, push, status, attempts, error, duration_ms, created_at, next_attempt_at, reloaded, announce
+FROM deliveries `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries table: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*blog.Delivery
+	for rows.Next() {
+		var (
+			d          blog.Delivery
+			durationMS int64
+			announce   string
+		)
+		if err := rows.Scan(&d.ID, &d.Provider, &d.Branch, &d.Before, &d.After, &d.Push, &d.Status, &d.Attempts, &d.Error, &durationMS, &d.CreatedAt, &d.NextAttemptAt, &d.Reloaded, &announce); err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(durationMS) * time.Millisecond
+		// the URIs are stored one per line
+		if announce != "" {
+			d.Announce = strings.Split(announce, "\n")
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestDeliveryService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	ds := NewDeliveryService(db)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"first", "second"} {
+		require.NoError(t, ds.CreateDelivery(&blog.Delivery{
+			ID:            id,
+			Provider:      "github",
+			Branch:        "main",
+			After:         id + "-commit",
+			Push:          []byte(`{}`),
+			Status:        blog.DeliveryPending,
+			CreatedAt:     now.Add(time.Duration(i) * time.Minute),
+			NextAttemptAt: now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	assert.ErrorIs(t, ds.CreateDelivery(&blog.Delivery{ID: "first", Status: blog.DeliveryPending}), blog.ErrDeliveryExists)
+
+	due, err := ds.DueDeliveries(now)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "first", due[0].ID)
+	assert.Equal(t, []byte(`{}`), due[0].Push)
+	assert.True(t, now.Equal(due[0].CreatedAt))
+
+	due[0].Status = blog.DeliveryFailed
+	due[0].Attempts = 5
+	due[0].Error = "failed to fetch"
+	due[0].Duration = 1500 * time.Millisecond
+	due[0].Reloaded = true
+	due[0].Announce = []string{"/2021/01/01/hello.md", "/2021/01/02/world.md"}
+	require.NoError(t, ds.UpdateDelivery(due[0]))
+
+	delivered, err := ds.IsCommitDelivered("first-commit")
+	require.NoError(t, err)
+	assert.False(t, delivered)
+	delivered, err = ds.IsCommitDelivered("second-commit")
+	require.NoError(t, err)
+	assert.True(t, delivered)
+
+	due, err = ds.DueDeliveries(now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "second", due[0].ID)
+
+	recent, err := ds.RecentDeliveries(10)
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "second", recent[0].ID)
+	assert.Equal(t, "failed to fetch", recent[1].Error)
+	assert.Equal(t, 5, recent[1].Attempts)
+	assert.Equal(t, 1500*time.Millisecond, recent[1].Duration)
+	assert.True(t, recent[1].Reloaded)
+	assert.Equal(t, []string{"/2021/01/01/hello.md", "/2021/01/02/world.md"}, recent[1].Announce)
+	assert.False(t, recent[0].Reloaded)
+	assert.Nil(t, recent[0].Announce)
+}
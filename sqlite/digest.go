@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+type digestService struct {
+	db *DB
+}
+
+// NewDigestService returns a service recording the newsletter digests into SQLite
+func NewDigestService(db *DB) blog.DigestService {
+	return &digestService{
+		db: db,
+	}
+}
+
+func (s *digestService) LastDigest() (*blog.Digest, error) {
+	var d blog.Digest
+	err := s.db.sqlDB.QueryRow(`
+SELECT id, window_start, window_end, status, posts, recipients, created_at, sent_at
+FROM digests
+ORDER BY id DESC
+LIMIT 1`).Scan(&d.ID, &d.From, &d.To, &d.Status, &d.Posts, &d.Recipients, &d.CreatedAt, &d.SentAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the last digest: %w", err)
+	}
+
+	return &d, nil
+}
+
+func (s *digestService) CreateDigest(d *blog.Digest) error {
+	result, err := s.db.sqlDB.Exec(`
+INSERT INTO digests (window_start, window_end, status, posts, created_at)
+VALUES (?, ?, ?, ?, ?)`,
+		d.From.UTC(), d.To.UTC(), d.Status, d.Posts, d.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into digests table: %w", err)
+	}
+
+	d.ID, err = result.LastInsertId()
+	return err
+}
+
+func (s *digestService) CompleteDigest(d *blog.Digest) error {
+	if err := s.db.sqlDB.QueryRow(`SELECT COUNT(*) FROM digest_recipients WHERE digest_id = ?`, d.ID).Scan(&d.Recipients); err != nil {
+		return fmt.Errorf("failed to count the recipients of digest %d: %w", d.ID, err)
+	}
+
+	var sentAt interface{}
+	if d.SentAt != nil {
+		sentAt = d.SentAt.UTC()
+	}
+	if _, err := s.db.sqlDB.Exec(`
+UPDATE digests
+SET status = ?, posts = ?, recipients = ?, sent_at = ?
+WHERE id = ?`,
+		d.Status, d.Posts, d.Recipients, sentAt, d.ID); err != nil {
+		return fmt.Errorf("failed to update digest %d: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+func (s *digestService) IsDigestSentTo(id int64, email string) (bool, error) {
+	var n int
+	if err := s.db.sqlDB.QueryRow(`SELECT COUNT(*) FROM digest_recipients WHERE digest_id = ? AND email = ?`, id, email).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to find the recipient %s of digest %d: %w", email, id, err)
+	}
+
+	return n > 0, nil
+}
+
+func (s *digestService) AddDigestRecipient(id int64, email string, sentAt time.Time) error {
+	if _, err := s.db.sqlDB.Exec(`INSERT OR IGNORE INTO digest_recipients (digest_id, email, sent_at) VALUES (?, ?, ?)`,
+		id, email, sentAt.UTC()); err != nil {
+		return fmt.Errorf("failed to insert into digest_recipients table: %w", err)
+	}
+
+	return nil
+}
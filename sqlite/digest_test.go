@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestDigestService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	ds := NewDigestService(db)
+
+	last, err := ds.LastDigest()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	now := time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)
+	d := &blog.Digest{
+		From:      now.AddDate(0, 0, -7),
+		To:        now,
+		Status:    blog.DigestPending,
+		CreatedAt: now,
+	}
+	require.NoError(t, ds.CreateDigest(d))
+	assert.Equal(t, int64(1), d.ID)
+
+	require.NoError(t, ds.AddDigestRecipient(d.ID, "foo@example.com", now))
+	require.NoError(t, ds.AddDigestRecipient(d.ID, "foo@example.com", now))
+	sent, err := ds.IsDigestSentTo(d.ID, "foo@example.com")
+	require.NoError(t, err)
+	assert.True(t, sent)
+	sent, err = ds.IsDigestSentTo(d.ID, "bar@example.com")
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	last, err = ds.LastDigest()
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, blog.DigestPending, last.Status)
+	assert.True(t, now.AddDate(0, 0, -7).Equal(last.From))
+	assert.True(t, now.Equal(last.To))
+	assert.Nil(t, last.SentAt)
+
+	sentAt := now.Add(time.Minute)
+	last.Status = blog.DigestSent
+	last.Posts = 2
+	last.SentAt = &sentAt
+	require.NoError(t, ds.CompleteDigest(last))
+	assert.Equal(t, 1, last.Recipients)
+
+	last, err = ds.LastDigest()
+	require.NoError(t, err)
+	assert.Equal(t, blog.DigestSent, last.Status)
+	assert.Equal(t, 2, last.Posts)
+	assert.Equal(t, 1, last.Recipients)
+	require.NotNil(t, last.SentAt)
+	assert.True(t, sentAt.Equal(*last.SentAt))
+}
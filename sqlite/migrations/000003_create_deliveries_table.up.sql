@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS deliveries (
+    id              TEXT PRIMARY KEY,
+    provider        TEXT NOT NULL,
+    branch          TEXT NOT NULL,
+    before_commit   TEXT NOT NULL,
+    after_commit    TEXT NOT NULL,
+    push            BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    duration_ms     INTEGER NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS deliveries_after_commit ON deliveries (after_commit);
+CREATE INDEX IF NOT EXISTS deliveries_status_next_attempt_at ON deliveries (status, next_attempt_at);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS redirects (
+    from_uri   TEXT PRIMARY KEY,
+    to_uri     TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS redirects_to_uri ON redirects (to_uri);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS subscribers (
+    email            TEXT PRIMARY KEY,
+    status           TEXT NOT NULL,
+    token            TEXT NOT NULL DEFAULT '',
+    token_expires_at TIMESTAMP,
+    created_at       TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL,
+    confirmed_at     TIMESTAMP,
+    unsubscribed_at  TIMESTAMP,
+    bounced_at       TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS subscribers_token ON subscribers (token);
+CREATE INDEX IF NOT EXISTS subscribers_status ON subscribers (status);
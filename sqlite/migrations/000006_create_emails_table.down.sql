@@ -0,0 +1 @@
+DROP TABLE IF EXISTS emails;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS emails (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient       TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    message         BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    sent_at         TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS emails_status_next_attempt_at ON emails (status, next_attempt_at);
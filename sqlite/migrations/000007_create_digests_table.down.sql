@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS digest_recipients;
+DROP TABLE IF EXISTS digests;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS digests (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    window_start TIMESTAMP NOT NULL,
+    window_end   TIMESTAMP NOT NULL,
+    status       TEXT NOT NULL,
+    posts        INTEGER NOT NULL DEFAULT 0,
+    recipients   INTEGER NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP NOT NULL,
+    sent_at      TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS digest_recipients (
+    digest_id INTEGER NOT NULL REFERENCES digests (id),
+    email     TEXT NOT NULL,
+    sent_at   TIMESTAMP NOT NULL,
+    PRIMARY KEY (digest_id, email)
+);
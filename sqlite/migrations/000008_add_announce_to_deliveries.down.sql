@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS deliveries_previous (
+    id              TEXT PRIMARY KEY,
+    provider        TEXT NOT NULL,
+    branch          TEXT NOT NULL,
+    before_commit   TEXT NOT NULL,
+    after_commit    TEXT NOT NULL,
+    push            BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    duration_ms     INTEGER NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL
+);
+INSERT INTO deliveries_previous
+SELECT id, provider, branch, before_commit, after_commit, push, status, attempts, error, duration_ms, created_at, next_attempt_at
+FROM deliveries;
+DROP TABLE deliveries;
+ALTER TABLE deliveries_previous RENAME TO deliveries;
+CREATE INDEX IF NOT EXISTS deliveries_after_commit ON deliveries (after_commit);
+CREATE INDEX IF NOT EXISTS deliveries_status_next_attempt_at ON deliveries (status, next_attempt_at);
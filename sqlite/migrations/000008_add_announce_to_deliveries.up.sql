@@ -0,0 +1,2 @@
+ALTER TABLE deliveries ADD COLUMN reloaded INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE deliveries ADD COLUMN announce TEXT NOT NULL DEFAULT '';
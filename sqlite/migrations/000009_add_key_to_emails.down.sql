@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS emails_idempotency_key;
+CREATE TABLE IF NOT EXISTS emails_previous (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient       TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    message         BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    sent_at         TIMESTAMP
+);
+INSERT INTO emails_previous
+SELECT id, recipient, subject, message, status, attempts, error, created_at, next_attempt_at, sent_at
+FROM emails;
+DROP TABLE emails;
+ALTER TABLE emails_previous RENAME TO emails;
+CREATE INDEX IF NOT EXISTS emails_status_next_attempt_at ON emails (status, next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE emails ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS emails_idempotency_key ON emails (idempotency_key);
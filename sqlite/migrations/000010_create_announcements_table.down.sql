@@ -0,0 +1 @@
+DROP TABLE IF EXISTS announcements;
\ No newline at end of file
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS announcements (
+    id              INTEGER PRIMARY KEY CHECK (id = 1),
+    announced_until TIMESTAMP NOT NULL
+);
@@ -0,0 +1,247 @@
+package sqlite
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/hash"
+)
+
+const (
+	invalidTokenMessage = "The confirmation link is invalid or has already been used."
+	expiredTokenMessage = "The confirmation link has expired. Please subscribe again to receive a new one."
+	invalidEmailMessage = "Invalid email address."
+	invalidHashMessage  = "Either email or hash is invalid"
+)
+
+type subscriberService struct {
+	db  *DB
+	now func() time.Time
+}
+
+// NewSubscriberService returns a service managing the subscribers stored into SQLite
+func NewSubscriberService(db *DB) blog.SubscriberService {
+	return newSubscriberService(db)
+}
+
+func newSubscriberService(db *DB) *subscriberService {
+	return &subscriberService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// NewsletterService stores the subscribers into SQLite, it answers like the newsletter service
+// so that the blog can run without it
+type NewsletterService struct {
+	*subscriberService
+	smtpService blog.SMTPService
+	tokenExpiry time.Duration
+}
+
+// NewNewsletterService returns a newsletter service which sends the emails through smtpService,
+// the confirmation tokens expire after tokenExpiry
+func NewNewsletterService(db *DB, smtpService blog.SMTPService, tokenExpiry time.Duration) *NewsletterService {
+	return &NewsletterService{
+		subscriberService: newSubscriberService(db),
+		smtpService:       smtpService,
+		tokenExpiry:       tokenExpiry,
+	}
+}
+
+// Subscribe stores a pending subscriber and sends the confirmation email, a new token is sent
+// when the previous one has expired, when it is resent or when an unsubscribed or bounced email subscribes again
+func (s *NewsletterService) Subscribe(r *http.Request, body io.Reader) (*http.Response, error) {
+	var req struct {
+		Email  string `json:"email"`
+		Resend bool   `json:"resend"`
+	}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return jsonResponse(http.StatusBadRequest, "message", invalidEmailMessage)
+	}
+	a, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, "message", invalidEmailMessage)
+	}
+	email := strings.ToLower(a.Address)
+
+	subscriber, err := s.FindSubscriber(email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	if subscriber != nil {
+		switch subscriber.Status {
+		case blog.SubscriberActive:
+			return jsonResponse(http.StatusConflict, "message", "already subscribed")
+		case blog.SubscriberPending:
+			if !req.Resend && now.Before(subscriber.TokenExpiresAt) {
+				return jsonResponse(http.StatusUnauthorized, "message", "subscription is pending")
+			}
+		}
+	}
+
+	token := s.smtpService.GenerateNewUUID()
+	if _, err := s.db.sqlDB.Exec(`
+INSERT INTO subscribers (email, status, token, token_expires_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (email) DO UPDATE SET status = excluded.status, token = excluded.token, token_expires_at = excluded.token_expires_at, updated_at = excluded.updated_at`,
+		email, blog.SubscriberPending, token, now.Add(s.tokenExpiry), now, now); err != nil {
+		return nil, fmt.Errorf("failed to insert into subscribers table: %w", err)
+	}
+
+	if err := s.smtpService.SendConfirmationEmail(email, token); err != nil {
+		return nil, fmt.Errorf("failed to send confirmation email to %s: %w", email, err)
+	}
+
+	return jsonResponse(http.StatusOK, "message", "confirmation email sent")
+}
+
+// Confirm activates the subscriber of the token, then sends the thank-you email
+func (s *NewsletterService) Confirm(r *http.Request, token string) (*http.Response, error) {
+	subscriber, err := s.findSubscriber(`WHERE token = ? AND token != '' AND status = ?`, token, blog.SubscriberPending)
+	if err != nil {
+		return nil, err
+	}
+	if subscriber == nil {
+		return jsonResponse(http.StatusNotFound, "message", invalidTokenMessage)
+	}
+
+	now := s.now().UTC()
+	if !now.Before(subscriber.TokenExpiresAt) {
+		return jsonResponse(http.StatusGone, "message", expiredTokenMessage)
+	}
+
+	if _, err := s.db.sqlDB.Exec(`
+UPDATE subscribers
+SET status = ?, token = '', token_expires_at = NULL, confirmed_at = ?, unsubscribed_at = NULL, bounced_at = NULL, updated_at = ?
+WHERE email = ?`,
+		blog.SubscriberActive, now, now, subscriber.Email); err != nil {
+		return nil, fmt.Errorf("failed to confirm subscriber %s: %w", subscriber.Email, err)
+	}
+
+	if err := s.smtpService.SendThankYouEmail(subscriber.Email); err != nil {
+		return nil, fmt.Errorf("failed to send thank you email to %s: %w", subscriber.Email, err)
+	}
+
+	return jsonResponse(http.StatusOK, "message", "subscription confirmed")
+}
+
+// Unsubscribe checks the HMAC of the email which is sent in the newsletters, unsubscribing twice is not an error
+func (s *NewsletterService) Unsubscribe(r *http.Request, email, hashValue string) (*http.Response, error) {
+	expected, err := hash.ComputeHmac256(email, s.smtpService.GetHMACSecret())
+	if err != nil {
+		return nil, err
+	}
+	if email == "" || !hmac.Equal([]byte(expected), []byte(hashValue)) {
+		return jsonResponse(http.StatusBadRequest, "message", invalidHashMessage)
+	}
+
+	subscriber, err := s.FindSubscriber(email)
+	if err != nil {
+		return nil, err
+	}
+	if subscriber == nil {
+		return jsonResponse(http.StatusBadRequest, "message", invalidHashMessage)
+	}
+
+	if subscriber.Status != blog.SubscriberUnsubscribed {
+		now := s.now().UTC()
+		if _, err := s.db.sqlDB.Exec(`
+UPDATE subscribers
+SET status = ?, token = '', token_expires_at = NULL, unsubscribed_at = ?, updated_at = ?
+WHERE email = ?`,
+			blog.SubscriberUnsubscribed, now, now, subscriber.Email); err != nil {
+			return nil, fmt.Errorf("failed to unsubscribe %s: %w", subscriber.Email, err)
+		}
+	}
+
+	return jsonResponse(http.StatusOK, "message", "unsubscribed")
+}
+
+// FindSubscriber returns the subscriber of the email, nil if it is not found
+func (s *subscriberService) FindSubscriber(email string) (*blog.Subscriber, error) {
+	return s.findSubscriber(`WHERE email = ?`, strings.ToLower(email))
+}
+
+// ActiveSubscribers returns the subscribers who confirmed their subscription
+func (s *subscriberService) ActiveSubscribers() ([]*blog.Subscriber, error) {
+	return s.findSubscribers(`WHERE status = ? ORDER BY confirmed_at`, blog.SubscriberActive)
+}
+
+// MarkBounced stops sending emails to a subscriber whose address was rejected
+func (s *subscriberService) MarkBounced(email string) error {
+	now := s.now().UTC()
+	if _, err := s.db.sqlDB.Exec(`
+UPDATE subscribers
+SET status = ?, token = '', token_expires_at = NULL, bounced_at = ?, updated_at = ?
+WHERE email = ?`,
+		blog.SubscriberBounced, now, now, strings.ToLower(email)); err != nil {
+		return fmt.Errorf("failed to mark %s as bounced: %w", email, err)
+	}
+
+	return nil
+}
+
+func (s *subscriberService) findSubscriber(where string, args ...interface{}) (*blog.Subscriber, error) {
+	subscribers, err := s.findSubscribers(where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscribers) == 0 {
+		return nil, nil
+	}
+
+	return subscribers[0], nil
+}
+
+func (s *subscriberService) findSubscribers(where string, args ...interface{}) ([]*blog.Subscriber, error) {
+	rows, err := s.db.sqlDB.Query(`
+SELECT email, status, token, token_expires_at, created_at, updated_at, confirmed_at, unsubscribed_at, bounced_at
+FROM subscribers `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscribers table: %w", err)
+	}
+	defer rows.Close()
+
+	var subscribers []*blog.Subscriber
+	for rows.Next() {
+		var (
+			subscriber     blog.Subscriber
+			tokenExpiresAt sql.NullTime
+		)
+		if err := rows.Scan(&subscriber.Email, &subscriber.Status, &subscriber.Token, &tokenExpiresAt, &subscriber.CreatedAt, &subscriber.UpdatedAt,
+			&subscriber.ConfirmedAt, &subscriber.UnsubscribedAt, &subscriber.BouncedAt); err != nil {
+			return nil, err
+		}
+		subscriber.TokenExpiresAt = tokenExpiresAt.Time
+		subscribers = append(subscribers, &subscriber)
+	}
+
+	return subscribers, rows.Err()
+}
+
+// jsonResponse returns a response with a JSON object body, like the ones of the newsletter service
+func jsonResponse(status int, key, value string) (*http.Response, error) {
+	body, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
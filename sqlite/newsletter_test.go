@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+	"github.com/quantonganh/blog/pkg/hash"
+)
+
+type fakeSMTPService struct {
+	uuids         int
+	confirmations map[string]string
+	thanks        []string
+}
+
+func (s *fakeSMTPService) SendConfirmationEmail(to, token string) error {
+	s.confirmations[to] = token
+	return nil
+}
+
+func (s *fakeSMTPService) SendThankYouEmail(to string) error {
+	s.thanks = append(s.thanks, to)
+	return nil
+}
+
+func (s *fakeSMTPService) SendNewsletter(posts []*blog.Post) {}
+
+func (s *fakeSMTPService) SendNewsletterEmail(to, key string, newsletter *bytes.Buffer) error {
+	return nil
+}
+
+func (s *fakeSMTPService) GenerateNewUUID() string {
+	s.uuids++
+	return fmt.Sprintf("token-%d", s.uuids)
+}
+
+func (s *fakeSMTPService) GetHMACSecret() string {
+	return "secret"
+}
+
+func (s *fakeSMTPService) Stop() error {
+	return nil
+}
+
+func TestNewsletterService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	smtpService := &fakeSMTPService{confirmations: make(map[string]string)}
+	ns := NewNewsletterService(db, smtpService, time.Hour)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ns.now = func() time.Time {
+		return now
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", nil)
+	subscribe := func(email string) int {
+		resp, err := ns.Subscribe(r, strings.NewReader(fmt.Sprintf(`{"url": "http://localhost", "email": %q}`, email)))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	resend := func(email string) int {
+		resp, err := ns.Subscribe(r, strings.NewReader(fmt.Sprintf(`{"url": "http://localhost", "email": %q, "resend": true}`, email)))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	confirm := func(token string) (int, string) {
+		resp, err := ns.Confirm(r, token)
+		require.NoError(t, err)
+		var m map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+		return resp.StatusCode, m["message"]
+	}
+
+	assert.Equal(t, http.StatusBadRequest, subscribe("invalid"))
+	assert.Equal(t, http.StatusOK, subscribe("Foo@Example.com"))
+	assert.Equal(t, "token-1", smtpService.confirmations["foo@example.com"])
+	assert.Equal(t, http.StatusUnauthorized, subscribe("foo@example.com"))
+
+	now = now.Add(2 * time.Hour)
+	status, message := confirm("token-1")
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, expiredTokenMessage, message)
+
+	assert.Equal(t, http.StatusOK, subscribe("foo@example.com"))
+	assert.Equal(t, "token-2", smtpService.confirmations["foo@example.com"])
+	status, _ = confirm("token-1")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, http.StatusOK, resend("foo@example.com"))
+	assert.Equal(t, "token-3", smtpService.confirmations["foo@example.com"])
+	status, _ = confirm("token-2")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = confirm("token-3")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"foo@example.com"}, smtpService.thanks)
+	status, _ = confirm("token-3")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, http.StatusConflict, subscribe("foo@example.com"))
+	assert.Equal(t, http.StatusConflict, resend("foo@example.com"))
+
+	subscriber, err := ns.FindSubscriber("foo@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, blog.SubscriberActive, subscriber.Status)
+	assert.Empty(t, subscriber.Token)
+	require.NotNil(t, subscriber.ConfirmedAt)
+	assert.True(t, now.Equal(*subscriber.ConfirmedAt))
+	assert.True(t, now.Add(-2*time.Hour).Equal(subscriber.CreatedAt))
+
+	active, err := ns.ActiveSubscribers()
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+
+	resp, err := ns.Unsubscribe(r, "foo@example.com", "invalid")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	hashValue, err := hash.ComputeHmac256("foo@example.com", "secret")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		resp, err = ns.Unsubscribe(r, "foo@example.com", hashValue)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	subscriber, err = ns.FindSubscriber("foo@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, blog.SubscriberUnsubscribed, subscriber.Status)
+	require.NotNil(t, subscriber.UnsubscribedAt)
+	active, err = ns.ActiveSubscribers()
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	assert.Equal(t, http.StatusOK, subscribe("foo@example.com"))
+	require.NoError(t, ns.MarkBounced("foo@example.com"))
+	subscriber, err = ns.FindSubscriber("foo@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, blog.SubscriberBounced, subscriber.Status)
+	require.NotNil(t, subscriber.BouncedAt)
+
+	subscriber, err = ns.FindSubscriber("bar@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, subscriber)
+}
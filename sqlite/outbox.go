@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+type outboxService struct {
+	db *DB
+}
+
+// NewOutboxService returns a service storing the outbound emails into SQLite
+func NewOutboxService(db *DB) blog.OutboxService {
+	return &outboxService{
+		db: db,
+	}
+}
+
+// EnqueueEmail inserts a new email, it is ignored if its key already exists
+func (s *outboxService) EnqueueEmail(e *blog.Email) error {
+	// the emails without key are stored with a NULL key, which is never a duplicate
+	var key interface{}
+	if e.Key != "" {
+		key = e.Key
+	}
+	result, err := s.db.sqlDB.Exec(`
+INSERT INTO emails (recipient, subject, message, idempotency_key, status, attempts, error, created_at, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (idempotency_key) DO NOTHING`,
+		e.To, e.Subject, e.Message, key, e.Status, e.Attempts, e.Error, e.CreatedAt.UTC(), e.NextAttemptAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into emails table: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+func (s *outboxService) UpdateEmail(e *blog.Email) error {
+	var sentAt interface{}
+	if e.SentAt != nil {
+		sentAt = e.SentAt.UTC()
+	}
+	_, err := s.db.sqlDB.Exec(`
+UPDATE emails
+SET status = ?, attempts = ?, error = ?, next_attempt_at = ?, sent_at = ?
+WHERE id = ?`,
+		e.Status, e.Attempts, e.Error, e.NextAttemptAt.UTC(), sentAt, e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update email %d: %w", e.ID, err)
+	}
+
+	return nil
+}
+
+func (s *outboxService) DueEmails(now time.Time, limit int) ([]*blog.Email, error) {
+	rows, err := s.db.sqlDB.Query(`
+SELECT id, recipient, subject, message, status, attempts, error, created_at, next_attempt_at, sent_at
+FROM emails
+WHERE status = ? AND next_attempt_at <= ?
+ORDER BY id
+LIMIT ?`, blog.EmailPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query emails table: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []*blog.Email
+	for rows.Next() {
+		var e blog.Email
+		if err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Message, &e.Status, &e.Attempts, &e.Error, &e.CreatedAt, &e.NextAttemptAt, &e.SentAt); err != nil {
+			return nil, err
+		}
+		emails = append(emails, &e)
+	}
+
+	return emails, rows.Err()
+}
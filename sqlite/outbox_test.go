@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/blog"
+)
+
+func TestOutboxService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	outbox := NewOutboxService(db)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, to := range []string{"foo@example.com", "bar@example.com", "baz@example.com"} {
+		e := &blog.Email{
+			To:            to,
+			Subject:       "Hello",
+			Message:       []byte("Subject: Hello\r\n\r\nHello"),
+			Status:        blog.EmailPending,
+			CreatedAt:     now,
+			NextAttemptAt: now.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, outbox.EnqueueEmail(e))
+		assert.Equal(t, int64(i+1), e.ID)
+	}
+
+	due, err := outbox.DueEmails(now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "foo@example.com", due[0].To)
+	assert.Equal(t, []byte("Subject: Hello\r\n\r\nHello"), due[0].Message)
+	assert.Nil(t, due[0].SentAt)
+
+	sentAt := now.Add(time.Minute)
+	due[0].Status = blog.EmailSent
+	due[0].Attempts = 1
+	due[0].SentAt = &sentAt
+	require.NoError(t, outbox.UpdateEmail(due[0]))
+	due[1].Attempts = 1
+	due[1].Error = "451 try again later"
+	due[1].NextAttemptAt = now.Add(time.Hour)
+	require.NoError(t, outbox.UpdateEmail(due[1]))
+
+	due, err = outbox.DueEmails(now.Add(2*time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "baz@example.com", due[0].To)
+
+	due, err = outbox.DueEmails(now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "bar@example.com", due[0].To)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.Equal(t, "451 try again later", due[0].Error)
+
+	// an email is queued once per key
+	for i := 0; i < 2; i++ {
+		require.NoError(t, outbox.EnqueueEmail(&blog.Email{
+			To:            "qux@example.com",
+			Subject:       "Digest",
+			Message:       []byte("Subject: Digest\r\n\r\nDigest"),
+			Key:           "digest-1-qux@example.com",
+			Status:        blog.EmailPending,
+			CreatedAt:     now,
+			NextAttemptAt: now,
+		}))
+	}
+	due, err = outbox.DueEmails(now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 3)
+	assert.Equal(t, "qux@example.com", due[2].To)
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/blog"
+)
+
+type redirectService struct {
+	db *DB
+}
+
+// NewRedirectService returns a service storing the redirects into SQLite
+func NewRedirectService(db *DB) blog.RedirectService {
+	return &redirectService{
+		db: db,
+	}
+}
+
+// AddRedirect inserts the redirect in a transaction: the redirects to from are moved to to,
+// and the redirect from to is removed since to is served again
+func (s *redirectService) AddRedirect(from, to string) (err error) {
+	tx, err := s.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`UPDATE redirects SET to_uri = ? WHERE to_uri = ?`, to, from); err != nil {
+		return fmt.Errorf("failed to update redirects to %s: %w", from, err)
+	}
+	if _, err = tx.Exec(`DELETE FROM redirects WHERE from_uri = to_uri OR from_uri = ?`, to); err != nil {
+		return fmt.Errorf("failed to delete redirects from %s: %w", to, err)
+	}
+	if _, err = tx.Exec(`INSERT OR REPLACE INTO redirects (from_uri, to_uri, created_at) VALUES (?, ?, ?)`, from, to, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to insert into redirects table: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *redirectService) GetRedirect(from string) (string, error) {
+	var to string
+	err := s.db.sqlDB.QueryRow(`SELECT to_uri FROM redirects WHERE from_uri = ?`, from).Scan(&to)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find redirect from %s: %w", from, err)
+	}
+
+	return to, nil
+}
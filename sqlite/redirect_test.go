@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectService(t *testing.T) {
+	db := NewDB(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	rs := NewRedirectService(db)
+
+	redirects := func() map[string]string {
+		m := make(map[string]string)
+		for _, from := range []string{"/a.md", "/b.md", "/c.md"} {
+			to, err := rs.GetRedirect(from)
+			require.NoError(t, err)
+			if to != "" {
+				m[from] = to
+			}
+		}
+		return m
+	}
+
+	require.NoError(t, rs.AddRedirect("/a.md", "/b.md"))
+	require.NoError(t, rs.AddRedirect("/b.md", "/c.md"))
+	assert.Equal(t, map[string]string{"/a.md": "/c.md", "/b.md": "/c.md"}, redirects())
+
+	// a post renamed back to its first URI is served there again
+	require.NoError(t, rs.AddRedirect("/c.md", "/a.md"))
+	assert.Equal(t, map[string]string{"/b.md": "/a.md", "/c.md": "/a.md"}, redirects())
+}